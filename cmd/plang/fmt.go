@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/pechorka/plang/diag"
+	"github.com/pechorka/plang/format"
+)
+
+// runFmt formats the files in place, or stdin to stdout if no files are given,
+// and returns exit code.
+func runFmt(args []string) int {
+	flags := flag.NewFlagSet("fmt", flag.ExitOnError)
+	check := flags.Bool("check", false, "don't change files, list the files, that are not formatted, and fail if there are any")
+	noColor := flags.Bool("no-color", false, "don't colour error messages")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: plang fmt [flags] [files...]\n\n")
+		fmt.Fprintf(flags.Output(), "Formats the files in place or stdin to stdout, if no files are given.\n\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	color := !*noColor && diag.Colorable(os.Stderr)
+
+	if flags.NArg() == 0 {
+		src, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		out, ok := formatSource("<stdin>", src, color)
+		if !ok {
+			return 1
+		}
+		if *check {
+			if !bytes.Equal(src, out) {
+				fmt.Println("<stdin>")
+				return 1
+			}
+			return 0
+		}
+		os.Stdout.Write(out)
+		return 0
+	}
+
+	code := 0
+	for _, path := range flags.Args() {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			code = 1
+			continue
+		}
+		out, ok := formatSource(path, src, color)
+		if !ok {
+			code = 1
+			continue
+		}
+		if bytes.Equal(src, out) {
+			continue
+		}
+		if *check {
+			fmt.Println(path)
+			code = 1
+			continue
+		}
+		if err := os.WriteFile(path, out, 0o644); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			code = 1
+		}
+	}
+	return code
+}
+
+// formatSource formats the source and prints its syntax errors, if there are any.
+func formatSource(name string, src []byte, color bool) ([]byte, bool) {
+	out, err := format.Source(src)
+	var syntaxErr *format.SyntaxError
+	switch {
+	case errors.As(err, &syntaxErr):
+		r := diag.NewRenderer(name, string(src))
+		r.Color = color
+		r.Render(os.Stderr, syntaxErr.Diagnostics...)
+		return nil, false
+	case err != nil:
+		fmt.Fprintln(os.Stderr, err)
+		return nil, false
+	}
+	return out, true
+}
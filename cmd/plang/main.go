@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/pechorka/plang/diag"
+	"github.com/pechorka/plang/evaluator"
+	"github.com/pechorka/plang/lexer"
+	"github.com/pechorka/plang/object"
+	"github.com/pechorka/plang/parser"
+	"github.com/pechorka/plang/prelude"
+	"github.com/pechorka/plang/repl"
+)
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "fmt" {
+		os.Exit(runFmt(os.Args[2:]))
+	}
+
+	fsRoot := flag.String("fs", "", "grant scripts access to files under `dir`")
+	network := flag.Bool("net", false, "grant scripts network access")
+	process := flag.Bool("proc", false, "grant scripts access to arguments, environment, exit status and other programs")
+	noPrelude := flag.Bool("no-prelude", false, "don't load the standard prelude")
+	noColor := flag.Bool("no-color", false, "don't colour error messages")
+	seed := flag.Int64("seed", 0, "seed random builtins, so runs are reproducible (default is random seed)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: plang [flags] [script.pl [args...]]\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "       plang fmt [-check] [files...]\n\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "Runs the script or starts the REPL, if no script is given.\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "fmt formats the files, see plang fmt -h.\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	var opts []evaluator.Option
+	if *fsRoot != "" {
+		opts = append(opts, evaluator.WithFS(*fsRoot))
+	}
+	if *network {
+		opts = append(opts, evaluator.WithNetwork())
+	}
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "seed" {
+			opts = append(opts, evaluator.WithSeed(*seed))
+		}
+	})
+	if *process {
+		var scriptArgs []string
+		if flag.NArg() > 1 {
+			scriptArgs = flag.Args()[1:]
+		}
+		opts = append(opts, evaluator.WithProcess(scriptArgs))
+	}
+
+	if flag.NArg() == 0 {
+		repl.Start(os.Stdin, os.Stdout, !*noPrelude, opts...)
+		return
+	}
+	os.Exit(run(flag.Arg(0), !*noPrelude, !*noColor && diag.Colorable(os.Stderr), opts))
+}
+
+// run executes the script and returns exit code.
+// Errors are printed with the lines of the script, where they happened.
+func run(path string, withPrelude, color bool, opts []evaluator.Option) int {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	r := diag.NewRenderer(path, string(src))
+	r.Color = color
+
+	p := parser.New(lexer.NewNamed(path, bytes.NewReader(src)))
+	program := p.Parse()
+	if len(p.Diagnostics()) != 0 {
+		r.Render(os.Stderr, p.Diagnostics()...)
+		return 1
+	}
+
+	eval := evaluator.New(opts...)
+	env := object.NewEnvironment()
+	if withPrelude {
+		base, err := prelude.Load(eval)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		env = object.NewEnclosedEnvironment(base)
+	}
+	result := eval.Eval(program, env)
+	if errObj, ok := result.(*object.Error); ok {
+		if code, ok := errObj.ExitCode(); ok {
+			return code
+		}
+		r.Render(os.Stderr, errObj.Diagnostic())
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,194 @@
+// Package cst provides lossless concrete syntax tree of plang source.
+// Unlike the ast, it keeps every token with its exact text, whitespace and comments,
+// so tools can rewrite parts of a file and print it back without changing the rest.
+package cst
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pechorka/plang/ast"
+	"github.com/pechorka/plang/diag"
+	"github.com/pechorka/plang/lexer"
+	"github.com/pechorka/plang/parser"
+	"github.com/pechorka/plang/token"
+)
+
+// Element is either *Node or *Token.
+type Element interface {
+	writeTo(out *strings.Builder)
+}
+
+// Token is a token with its source text and trivia before it.
+type Token struct {
+	Type    token.Type
+	Text    string // source text, e.g. literal of string with quotes
+	Pos     token.Position
+	Leading []lexer.Trivia // whitespace and comments before the token
+}
+
+func (t *Token) writeTo(out *strings.Builder) {
+	for _, tr := range t.Leading {
+		out.WriteString(tr.Text)
+	}
+	out.WriteString(t.Text)
+}
+
+func (t *Token) String() string {
+	var out strings.Builder
+	t.writeTo(&out)
+	return out.String()
+}
+
+// Node is a statement, expression or block. Children are in source order.
+type Node struct {
+	AST      ast.Node // the node of ast, that was parsed from the tokens of the node
+	Children []Element
+}
+
+func (n *Node) writeTo(out *strings.Builder) {
+	for _, c := range n.Children {
+		c.writeTo(out)
+	}
+}
+
+// String returns source text of the node, including trivia of its tokens.
+func (n *Node) String() string {
+	var out strings.Builder
+	n.writeTo(&out)
+	return out.String()
+}
+
+// Tokens returns tokens of the node in source order.
+func (n *Node) Tokens() []*Token {
+	var tokens []*Token
+	for _, c := range n.Children {
+		switch c := c.(type) {
+		case *Token:
+			tokens = append(tokens, c)
+		case *Node:
+			tokens = append(tokens, c.Tokens()...)
+		}
+	}
+	return tokens
+}
+
+type File struct {
+	Root *Node  // AST of the root is *ast.Program
+	EOF  *Token // end of input, its trivia is the end of the source
+}
+
+// String returns the source of the file. It is the same as the parsed source,
+// unless tokens were changed.
+func (f *File) String() string {
+	var out strings.Builder
+	f.Root.writeTo(&out)
+	f.EOF.writeTo(&out)
+	return out.String()
+}
+
+// Lower parses the text of the file to ast, so changes of tokens are taken into account.
+func (f *File) Lower() (*ast.Program, []diag.Diagnostic) {
+	p := parser.New(lexer.NewFromString(f.String()))
+	prog := p.Parse()
+	return prog, p.Diagnostics()
+}
+
+// Parse builds syntax tree of the source. Tokens, that are not part of any node
+// because of syntax errors, are children of the closest node, that contains them,
+// so the tree has all of the source even if there are errors.
+func Parse(src string) (*File, []diag.Diagnostic) {
+	rec := &recorder{l: lexer.NewFromString(src)}
+	p := parser.New(rec)
+	var ranges []nodeRange
+	p.OnNode(func(n ast.Node, first, last int) {
+		ranges = append(ranges, nodeRange{node: n, first: first, last: last, seq: len(ranges)})
+	})
+	prog := p.Parse()
+
+	tokens := rec.tokens[:len(rec.tokens)-1]
+	return &File{
+		Root: buildTree(&Node{AST: prog}, tokens, ranges),
+		EOF:  rec.tokens[len(rec.tokens)-1],
+	}, p.Diagnostics()
+}
+
+// recorder keeps tokens, that are read by the parser, up to EOF.
+type recorder struct {
+	l      *lexer.Lexer
+	tokens []*Token
+	eof    token.Token
+	done   bool
+}
+
+func (r *recorder) Next() token.Token {
+	if r.done {
+		return r.eof
+	}
+	tok := r.l.Next()
+	r.tokens = append(r.tokens, &Token{
+		Type:    tok.Type,
+		Text:    r.l.Text(),
+		Pos:     tok.Pos,
+		Leading: r.l.Trivia(),
+	})
+	if tok.Type == token.EOF {
+		r.eof, r.done = tok, true
+	}
+	return tok
+}
+
+func (r *recorder) Errors() []lexer.Error {
+	return r.l.Errors()
+}
+
+// nodeRange is node of the ast with indices of its first and last tokens.
+type nodeRange struct {
+	node        ast.Node
+	first, last int
+	seq         int // nodes are reported after their children
+}
+
+// buildTree nests ranges of the nodes and adds tokens to the innermost nodes, that contain them.
+func buildTree(root *Node, tokens []*Token, ranges []nodeRange) *Node {
+	for i := range ranges {
+		if ranges[i].last >= len(tokens) { // e.g. unclosed block ends at EOF
+			ranges[i].last = len(tokens) - 1
+		}
+	}
+	sort.Slice(ranges, func(i, j int) bool {
+		a, b := ranges[i], ranges[j]
+		if a.first != b.first {
+			return a.first < b.first
+		}
+		if a.last != b.last {
+			return a.last > b.last // outer node first
+		}
+		return a.seq > b.seq
+	})
+
+	type frame struct {
+		node *Node
+		last int
+	}
+	stack := []frame{{node: root, last: len(tokens) - 1}}
+	next := 0
+	for i, tok := range tokens {
+		for ; next < len(ranges) && ranges[next].first <= i; next++ {
+			r := ranges[next]
+			top := stack[len(stack)-1]
+			if r.first < i || r.last > top.last {
+				continue // overlaps other node, which can happen after syntax errors
+			}
+			n := &Node{AST: r.node}
+			top.node.Children = append(top.node.Children, n)
+			stack = append(stack, frame{node: n, last: r.last})
+		}
+		top := stack[len(stack)-1]
+		top.node.Children = append(top.node.Children, tok)
+		for len(stack) > 1 && stack[len(stack)-1].last <= i {
+			stack = stack[:len(stack)-1]
+		}
+	}
+	return root
+}
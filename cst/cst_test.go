@@ -0,0 +1,127 @@
+package cst
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pechorka/plang/ast"
+	"github.com/pechorka/plang/lexer"
+	"github.com/pechorka/plang/token"
+)
+
+func TestParse_roundTrip(t *testing.T) {
+	tests := []string{
+		"",
+		"   \n\t ",
+		"// only comment",
+		"let x = (1 + 2) * 3;",
+		"let  add =fn(a,b){\n\treturn a+b // sum\n};\r\nputs( add(1 , 2) ) ;\n\n",
+		"struct Point { x, y\n fn len() { this.x } }\nlet p = Point(1, 2); p.len()",
+		"try { throw \"héllo\" } catch (e) { e } finally { 0x_ff }",
+		"for (x in [1, 2][0:1]) { yield {\"a\": x} }",
+		// syntax errors keep all tokens
+		"let = 1; let y = 2 +; }} @ \x00 \xff",
+		"fn() { let s = \"unterminated",
+		"if (x { 1 } let y = )\nreturn 1",
+	}
+
+	files, err := filepath.Glob("../prelude/*.pl")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no prelude files: %v", err)
+	}
+	for _, name := range files {
+		src, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tests = append(tests, string(src))
+	}
+
+	for i, src := range tests {
+		f, _ := Parse(src)
+		if f.String() != src {
+			t.Errorf("test[%d]: source is not preserved.\nexpected=%q\ngot=     %q", i, src, f.String())
+		}
+	}
+}
+
+func TestParse_tree(t *testing.T) {
+	f, diags := Parse("let x = 1 + 2; // two\n")
+	if len(diags) != 0 {
+		t.Fatalf("unexpected errors: %v", diags)
+	}
+	if _, ok := f.Root.AST.(*ast.Program); !ok {
+		t.Fatalf("root is not program, got %T", f.Root.AST)
+	}
+	if len(f.Root.Children) != 1 {
+		t.Fatalf("root should have 1 child, got %d", len(f.Root.Children))
+	}
+
+	let, ok := f.Root.Children[0].(*Node)
+	if !ok {
+		t.Fatalf("child of root is not node, got %T", f.Root.Children[0])
+	}
+	if _, ok := let.AST.(*ast.LetStatement); !ok {
+		t.Fatalf("node is not let statement, got %T", let.AST)
+	}
+	if let.String() != "let x = 1 + 2;" {
+		t.Errorf("wrong text of let statement: %q", let.String())
+	}
+	if len(let.Children) != 5 {
+		t.Fatalf("let should have 5 children, got %d", len(let.Children))
+	}
+	sum, ok := let.Children[3].(*Node)
+	if !ok {
+		t.Fatalf("value of let is not node, got %T", let.Children[3])
+	}
+	if _, ok := sum.AST.(*ast.InfixExpression); !ok {
+		t.Fatalf("value of let is not infix expression, got %T", sum.AST)
+	}
+	if sum.String() != " 1 + 2" {
+		t.Errorf("wrong text of infix expression: %q", sum.String())
+	}
+	var types []token.Type
+	for _, tok := range sum.Tokens() {
+		types = append(types, tok.Type)
+	}
+	if len(types) != 3 || types[0] != token.INT || types[1] != token.PLUS || types[2] != token.INT {
+		t.Errorf("wrong tokens of infix expression: %v", types)
+	}
+
+	expectedTrivia := []lexer.Trivia{
+		{Kind: lexer.Whitespace, Text: " ", Pos: token.Position{Line: 1, Column: 15}},
+		{Kind: lexer.Comment, Text: "// two", Pos: token.Position{Line: 1, Column: 16}},
+		{Kind: lexer.Whitespace, Text: "\n", Pos: token.Position{Line: 1, Column: 22}},
+	}
+	if f.EOF.Type != token.EOF || len(f.EOF.Leading) != len(expectedTrivia) {
+		t.Fatalf("wrong trivia of EOF: %+v", f.EOF)
+	}
+	for i, tr := range f.EOF.Leading {
+		if tr != expectedTrivia[i] {
+			t.Errorf("trivia[%d] wrong. expected=%+v, got=%+v", i, expectedTrivia[i], tr)
+		}
+	}
+}
+
+func TestFile_Lower(t *testing.T) {
+	f, _ := Parse("let x = 1; // keep me\nputs(x)")
+	for _, tok := range f.Root.Tokens() {
+		if tok.Type == token.IDENT && tok.Text == "x" {
+			tok.Text = "renamed"
+		}
+	}
+
+	expectedSrc := "let renamed = 1; // keep me\nputs(renamed)"
+	if f.String() != expectedSrc {
+		t.Fatalf("wrong source. expected=%q, got=%q", expectedSrc, f.String())
+	}
+	prog, diags := f.Lower()
+	if len(diags) != 0 {
+		t.Fatalf("unexpected errors: %v", diags)
+	}
+	expectedProg := "let renamed = 1;puts(renamed)"
+	if prog.String() != expectedProg {
+		t.Errorf("wrong program. expected=%q, got=%q", expectedProg, prog.String())
+	}
+}
@@ -0,0 +1,92 @@
+// Package diag describes problems in plang source, that are found by the lexer,
+// the parser and the evaluator, and renders them with snippets of the source.
+package diag
+
+import (
+	"fmt"
+
+	"github.com/pechorka/plang/token"
+)
+
+type Severity int
+
+const (
+	Error Severity = iota
+	Warning
+	Note
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Error:
+		return "error"
+	case Warning:
+		return "warning"
+	case Note:
+		return "note"
+	}
+	return fmt.Sprintf("severity(%d)", int(s))
+}
+
+// Code identifies kind of the problem. Codes of runtime errors are their kinds, e.g. TypeError.
+type Code string
+
+// Codes of the lexer
+const (
+	InvalidCharacter   Code = "invalid-character"
+	InvalidEncoding    Code = "invalid-encoding"
+	UnterminatedString Code = "unterminated-string"
+	MalformedNumber    Code = "malformed-number"
+	ReadFailure        Code = "read-failure"
+)
+
+// Codes of the parser
+const (
+	UnexpectedToken Code = "unexpected-token"
+	UnclosedBlock   Code = "unclosed-block"
+	InvalidSyntax   Code = "invalid-syntax" // the rest of syntax errors
+)
+
+// Span is a range of the source. End is the position after the last rune,
+// it is the same as Start for empty spans, e.g. the end of input.
+type Span struct {
+	Start token.Position
+	End   token.Position
+}
+
+// SpanOf returns span of text, that starts at pos.
+func SpanOf(pos token.Position, text string) Span {
+	end := pos
+	for _, r := range text {
+		if r == '\n' {
+			end.Line++
+			end.Column = 1
+			continue
+		}
+		end.Column++
+	}
+	return Span{Start: pos, End: end}
+}
+
+// Label explains the related part of the source, e.g. where the unclosed block starts.
+type Label struct {
+	Span    Span
+	Message string
+}
+
+type Diagnostic struct {
+	Severity  Severity
+	Code      Code
+	Message   string
+	Primary   Span    // the offending part of the source, zero, if it is unknown
+	Secondary []Label // related parts of the source
+	Hints     []string
+}
+
+// Error returns the message prefixed with the position, so a diagnostic can be used as an error.
+func (d Diagnostic) Error() string {
+	if !d.Primary.Start.IsValid() {
+		return d.Message
+	}
+	return d.Primary.Start.String() + ": " + d.Message
+}
@@ -0,0 +1,206 @@
+package diag
+
+import (
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pechorka/plang/token"
+)
+
+// ANSI escape sequences
+const (
+	colorReset  = "\x1b[0m"
+	colorBold   = "\x1b[1m"
+	colorRed    = "\x1b[1;31m"
+	colorYellow = "\x1b[1;33m"
+	colorCyan   = "\x1b[1;36m"
+	colorBlue   = "\x1b[1;34m"
+)
+
+// Renderer prints diagnostics with the lines of the source they refer to:
+//
+//	error[unexpected-token]: expect next token to be "IDENT", got "=" instead
+//	 --> script.pl:1:5
+//	  |
+//	1 | let = 1;
+//	  |     ^
+//	  = hint: ...
+type Renderer struct {
+	Name  string // name of the source in locations, e.g. path of the script
+	Color bool   // highlight output with ANSI colours
+	lines []string
+}
+
+func NewRenderer(name, src string) *Renderer {
+	return &Renderer{
+		Name:  name,
+		lines: strings.Split(src, "\n"),
+	}
+}
+
+// Render prints the diagnostics one after another.
+func (r *Renderer) Render(w io.Writer, diags ...Diagnostic) error {
+	var out strings.Builder
+	for _, d := range diags {
+		r.render(&out, d)
+	}
+	_, err := io.WriteString(w, out.String())
+	return err
+}
+
+// span of the source, that is underlined with marker
+type underline struct {
+	span    Span
+	marker  byte
+	color   string
+	message string
+}
+
+func (r *Renderer) render(out *strings.Builder, d Diagnostic) {
+	sevColor := severityColor(d.Severity)
+	r.paint(out, sevColor, d.Severity.String())
+	if d.Code != "" {
+		r.paint(out, sevColor, "["+string(d.Code)+"]")
+	}
+	r.paint(out, colorBold, ": "+d.Message)
+	out.WriteString("\n")
+
+	underlines := []underline{{span: d.Primary, marker: '^', color: sevColor}}
+	for _, l := range d.Secondary {
+		underlines = append(underlines, underline{span: l.Span, marker: '-', color: colorBlue, message: l.Message})
+	}
+	// underlines of the lines, that are in the source
+	var shown []underline
+	for _, u := range underlines {
+		if u.span.Start.IsValid() && u.span.Start.Line <= len(r.lines) && r.inSource(u.span.Start) {
+			shown = append(shown, u)
+		}
+	}
+	sort.SliceStable(shown, func(i, j int) bool {
+		return shown[i].span.Start.Line < shown[j].span.Start.Line
+	})
+
+	maxLine := d.Primary.Start.Line
+	if len(shown) > 0 && shown[len(shown)-1].span.Start.Line > maxLine {
+		maxLine = shown[len(shown)-1].span.Start.Line
+	}
+	gutter := strings.Repeat(" ", len(strconv.Itoa(maxLine)))
+	if d.Primary.Start.IsValid() {
+		out.WriteString(gutter)
+		r.paint(out, colorBlue, "--> ")
+		if name := r.sourceName(d.Primary.Start); name != "" {
+			out.WriteString(name + ":")
+		}
+		out.WriteString(d.Primary.Start.String() + "\n")
+	}
+
+	if len(shown) > 0 {
+		r.paint(out, colorBlue, gutter+" |")
+		out.WriteString("\n")
+	}
+	for i, u := range shown {
+		line := u.span.Start.Line
+		if i == 0 || shown[i-1].span.Start.Line != line {
+			r.paint(out, colorBlue, padLeft(strconv.Itoa(line), len(gutter))+" |")
+			if text := r.lines[line-1]; text != "" {
+				out.WriteString(" " + text)
+			}
+			out.WriteString("\n")
+		}
+		r.paint(out, colorBlue, gutter+" | ")
+		r.underline(out, u)
+	}
+
+	for _, h := range d.Hints {
+		r.paint(out, colorBlue, gutter+" = ")
+		r.paint(out, colorCyan, "hint")
+		out.WriteString(": " + h + "\n")
+	}
+}
+
+// underline marks the span on its first line. Tabs of the line are kept,
+// so the markers are under the runes of the span.
+func (r *Renderer) underline(out *strings.Builder, u underline) {
+	line := []rune(r.lines[u.span.Start.Line-1])
+	start := u.span.Start.Column - 1
+	if start > len(line) {
+		start = len(line)
+	}
+	width := 1
+	if u.span.End.Line == u.span.Start.Line && u.span.End.Column > u.span.Start.Column {
+		width = u.span.End.Column - u.span.Start.Column
+	} else if u.span.End.Line > u.span.Start.Line && len(line) > start {
+		width = len(line) - start // the span continues on the next lines
+	}
+
+	var indent strings.Builder
+	for _, c := range line[:start] {
+		if c == '\t' {
+			indent.WriteRune('\t')
+		} else {
+			indent.WriteByte(' ')
+		}
+	}
+	out.WriteString(indent.String())
+	marks := strings.Repeat(string(u.marker), width)
+	if u.message != "" {
+		marks += " " + u.message
+	}
+	r.paint(out, u.color, marks)
+	out.WriteString("\n")
+}
+
+// inSource reports whether the position is in the rendered source.
+// Positions without source name are assumed to be in it.
+func (r *Renderer) inSource(pos token.Position) bool {
+	return pos.Source == "" || pos.Source == r.Name
+}
+
+func (r *Renderer) sourceName(pos token.Position) string {
+	if pos.Source != "" {
+		return pos.Source
+	}
+	return r.Name
+}
+
+func (r *Renderer) paint(out *strings.Builder, color, text string) {
+	if !r.Color {
+		out.WriteString(text)
+		return
+	}
+	out.WriteString(color + text + colorReset)
+}
+
+func severityColor(s Severity) string {
+	switch s {
+	case Error:
+		return colorRed
+	case Warning:
+		return colorYellow
+	}
+	return colorCyan
+}
+
+func padLeft(s string, width int) string {
+	if len(s) >= width {
+		return s
+	}
+	return strings.Repeat(" ", width-len(s)) + s
+}
+
+// Colorable reports whether colours should be used for w: it is a terminal
+// and the NO_COLOR environment variable is not set.
+func Colorable(w io.Writer) bool {
+	if _, ok := os.LookupEnv("NO_COLOR"); ok {
+		return false
+	}
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
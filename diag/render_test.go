@@ -0,0 +1,127 @@
+package diag
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pechorka/plang/token"
+)
+
+func TestSpanOf(t *testing.T) {
+	tests := []struct {
+		text        string
+		expectedEnd token.Position
+	}{
+		{"", token.Position{Line: 3, Column: 5}},
+		{"let", token.Position{Line: 3, Column: 8}},
+		{"\"héllo\"", token.Position{Line: 3, Column: 12}},
+		{"\"a\nbc", token.Position{Line: 4, Column: 3}},
+	}
+
+	start := token.Position{Line: 3, Column: 5}
+	for i, tt := range tests {
+		span := SpanOf(start, tt.text)
+		if span.Start != start || span.End != tt.expectedEnd {
+			t.Errorf("test[%d]: wrong span. expected=%v-%v, got=%v-%v",
+				i, start, tt.expectedEnd, span.Start, span.End)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	src := "let x = 1;\n\tlet y = x +;\n"
+	tests := []struct {
+		diag     Diagnostic
+		expected string
+	}{
+		{
+			Diagnostic{
+				Severity: Error,
+				Code:     UnexpectedToken,
+				Message:  `no prefix func for ";" token type`,
+				Primary:  SpanOf(token.Position{Line: 2, Column: 13}, ";"),
+			},
+			`error[unexpected-token]: no prefix func for ";" token type
+ --> script.pl:2:13
+  |
+2 | 	let y = x +;
+  | 	           ^
+`,
+		},
+		{
+			Diagnostic{
+				Severity:  Error,
+				Code:      UnclosedBlock,
+				Message:   "expected } at end of block",
+				Primary:   SpanOf(token.Position{Line: 3, Column: 1}, ""),
+				Secondary: []Label{{Span: SpanOf(token.Position{Line: 1, Column: 5}, "x = 1"), Message: "starts here"}},
+				Hints:     []string{"add }"},
+			},
+			`error[unclosed-block]: expected } at end of block
+ --> script.pl:3:1
+  |
+1 | let x = 1;
+  |     ----- starts here
+3 |
+  | ^
+  = hint: add }
+`,
+		},
+		{
+			// position is unknown, so there is no snippet
+			Diagnostic{Severity: Warning, Message: "something is off"},
+			"warning: something is off\n",
+		},
+		{
+			// position outside of the source
+			Diagnostic{Severity: Note, Code: "TypeError", Message: "in prelude", Primary: SpanOf(token.Position{Line: 10, Column: 1}, "x")},
+			"note[TypeError]: in prelude\n  --> script.pl:10:1\n",
+		},
+		{
+			// position in other source is not shown with the lines of this one
+			Diagnostic{
+				Severity: Error,
+				Message:  "in other file",
+				Primary:  SpanOf(token.Position{Line: 1, Column: 5, Source: "lib.pl"}, "x"),
+			},
+			"error: in other file\n --> lib.pl:1:5\n",
+		},
+		{
+			Diagnostic{
+				Severity: Error,
+				Message:  "in this file",
+				Primary:  SpanOf(token.Position{Line: 1, Column: 5, Source: "script.pl"}, "x"),
+			},
+			"error: in this file\n --> script.pl:1:5\n  |\n1 | let x = 1;\n  |     ^\n",
+		},
+	}
+
+	r := NewRenderer("script.pl", src)
+	for i, tt := range tests {
+		var out strings.Builder
+		if err := r.Render(&out, tt.diag); err != nil {
+			t.Fatalf("test[%d]: render failed: %s", i, err)
+		}
+		if out.String() != tt.expected {
+			t.Errorf("test[%d]: wrong output.\nexpected:\n%s\ngot:\n%s", i, tt.expected, out.String())
+		}
+	}
+}
+
+func TestRender_color(t *testing.T) {
+	r := NewRenderer("", "1 + @")
+	r.Color = true
+	var out strings.Builder
+	r.Render(&out, Diagnostic{
+		Severity: Error,
+		Code:     InvalidCharacter,
+		Message:  "unexpected character '@'",
+		Primary:  SpanOf(token.Position{Line: 1, Column: 5}, "@"),
+	})
+	if !strings.Contains(out.String(), colorRed+"^"+colorReset) {
+		t.Errorf("caret is not coloured:\n%q", out.String())
+	}
+	if !strings.HasPrefix(out.String(), colorRed+"error"+colorReset) {
+		t.Errorf("severity is not coloured:\n%q", out.String())
+	}
+}
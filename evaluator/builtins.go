@@ -2,35 +2,35 @@ package evaluator
 
 import (
 	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/pechorka/plang/object"
 )
 
 var builtins = map[string]*object.Builtin{
 	"len": {
-		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 1 {
-				return newError(`wrong number of arguments. got=%d, want=%d`,
-					len(args), 1)
-			}
+		Params: []string{"value"},
+		Doc:    "Returns number of characters of a string or elements of an array.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
 			switch arg := args[0].(type) {
 			case *object.String:
-				return &object.Integer{Value: int64(len(arg.Value))}
+				return &object.Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
 			case *object.Array:
 				return &object.Integer{Value: int64(len(arg.Elements))}
 			default:
-				return newError("argument to `len` not supported, got %s", args[0].Type())
+				return newTypeError("argument to `len` not supported, got %s", args[0].Type())
 			}
 		}},
 	"first": {
-		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 1 {
-				return newError(`wrong number of arguments. got=%d, want=%d`,
-					len(args), 1)
-			}
+		Params: []string{"arr"},
+		Doc:    "Returns the first element of the array or null, if it is empty.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
 			arr, ok := args[0].(*object.Array)
 			if !ok {
-				return newError("argument to `first` must be ARRAY, got %s", args[0].Type())
+				return newTypeError("argument to `first` must be ARRAY, got %s", args[0].Type())
 			}
 			if len(arr.Elements) == 0 {
 				return NULL
@@ -39,14 +39,12 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"last": {
-		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 1 {
-				return newError(`wrong number of arguments. got=%d, want=%d`,
-					len(args), 1)
-			}
+		Params: []string{"arr"},
+		Doc:    "Returns the last element of the array or null, if it is empty.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
 			arr, ok := args[0].(*object.Array)
 			if !ok {
-				return newError("argument to `last` must be ARRAY, got %s", args[0].Type())
+				return newTypeError("argument to `last` must be ARRAY, got %s", args[0].Type())
 			}
 			if len(arr.Elements) == 0 {
 				return NULL
@@ -55,14 +53,12 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"rest": {
-		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 1 {
-				return newError(`wrong number of arguments. got=%d, want=%d`,
-					len(args), 1)
-			}
+		Params: []string{"arr"},
+		Doc:    "Returns new array without the first element or null, if the array is empty.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
 			arr, ok := args[0].(*object.Array)
 			if !ok {
-				return newError("argument to `rest` must be ARRAY, got %s", args[0].Type())
+				return newTypeError("argument to `rest` must be ARRAY, got %s", args[0].Type())
 			}
 			if len(arr.Elements) == 0 {
 				return NULL
@@ -73,14 +69,12 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"push": {
-		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 2 {
-				return newError(`wrong number of arguments. got=%d, want=%d`,
-					len(args), 2)
-			}
+		Params: []string{"arr", "value"},
+		Doc:    "Returns new array with the value appended.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
 			arr, ok := args[0].(*object.Array)
 			if !ok {
-				return newError("first argument to `push` must be ARRAY, got %s", args[0].Type())
+				return newTypeError("first argument to `push` must be ARRAY, got %s", args[0].Type())
 			}
 			newElements := make([]object.Object, len(arr.Elements)+1)
 			copy(newElements, arr.Elements)
@@ -88,12 +82,619 @@ var builtins = map[string]*object.Builtin{
 			return &object.Array{Elements: newElements}
 		},
 	},
+	"type": {
+		Params: []string{"value"},
+		Doc:    "Returns name of the type of the value, e.g. \"INTEGER\" or \"struct Point\" for instances of Point.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			return &object.String{Value: string(args[0].Type())}
+		},
+	},
+	"iter": {
+		Params: []string{"iterable"},
+		Doc:    "Returns iterator over the values of the iterable.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			it, err := iterate(args[0])
+			if err != nil {
+				return err
+			}
+			return it
+		},
+	},
+	"next": {
+		Params: []string{"iterator"},
+		Doc:    "Returns the next value of the iterator or null, when it is exhausted.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			it, ok := args[0].(*object.Iterator)
+			if !ok {
+				return newTypeError("argument to `next` must be ITERATOR, got %s", args[0].Type())
+			}
+			val, ok := it.Next()
+			if !ok {
+				return NULL
+			}
+			return val
+		},
+	},
+	"collect": {
+		Params: []string{"iterable"},
+		Doc:    "Reads all values of the iterable into an array.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			it, err := iterate(args[0])
+			if err != nil {
+				return err
+			}
+			return collect(it, -1)
+		},
+	},
+	"take": {
+		Params: []string{"iterable", "n"},
+		Doc:    "Reads at most n values of the iterable into an array.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			it, err := iterate(args[0])
+			if err != nil {
+				return err
+			}
+			n, ok := args[1].(*object.Integer)
+			if !ok {
+				return newTypeError("second argument to `take` must be INTEGER, got %s", args[1].Type())
+			}
+			if n.Value < 0 {
+				return newArgumentError("second argument to `take` must not be negative, got %d", n.Value)
+			}
+			return collect(it, int(n.Value))
+		},
+	},
+	"zip": {
+		Params: []string{"iterable", "iterables..."},
+		Doc:    "Returns iterator of arrays with a value from each iterable. It stops at the shortest iterable.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			its := make([]*object.Iterator, len(args))
+			for i, arg := range args {
+				it, err := iterate(arg)
+				if err != nil {
+					return err
+				}
+				its[i] = it
+			}
+			return &object.Iterator{Next: func() (object.Object, bool) {
+				elements := make([]object.Object, len(its))
+				for i, it := range its {
+					val, ok := it.Next()
+					if !ok {
+						return nil, false
+					}
+					if isError(val) {
+						return val, true
+					}
+					elements[i] = val
+				}
+				return &object.Array{Elements: elements}, true
+			}}
+		},
+	},
+	"enumerate": {
+		Params: []string{"iterable"},
+		Doc:    "Returns iterator of [index, value] pairs.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			it, err := iterate(args[0])
+			if err != nil {
+				return err
+			}
+			var i int64
+			return &object.Iterator{Next: func() (object.Object, bool) {
+				val, ok := it.Next()
+				if !ok || isError(val) {
+					return val, ok
+				}
+				i++
+				return &object.Array{Elements: []object.Object{&object.Integer{Value: i - 1}, val}}, true
+			}}
+		},
+	},
+	"map": {
+		Params: []string{"iterable", "f"},
+		Doc:    "Returns array of results of f applied to every value.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			it, err := iterate(args[0])
+			if err != nil {
+				return err
+			}
+			elements := []object.Object{}
+			res := forEach(it, func(val object.Object) object.Object {
+				mapped := in.Apply(args[1], val)
+				if isError(mapped) {
+					return mapped
+				}
+				elements = append(elements, mapped)
+				return nil
+			})
+			if res != nil {
+				return res
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+	"filter": {
+		Params: []string{"iterable", "pred"},
+		Doc:    "Returns array of values, for which pred returns a truthy value.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			it, err := iterate(args[0])
+			if err != nil {
+				return err
+			}
+			elements := []object.Object{}
+			res := forEach(it, func(val object.Object) object.Object {
+				keep := in.Apply(args[1], val)
+				if isError(keep) {
+					return keep
+				}
+				if isTruthy(keep) {
+					elements = append(elements, val)
+				}
+				return nil
+			})
+			if res != nil {
+				return res
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+	"reduce": {
+		Params: []string{"iterable", "f", "initial?"},
+		Doc:    "Combines values with f(acc, value), starting from initial or the first value.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			it, err := iterate(args[0])
+			if err != nil {
+				return err
+			}
+			var acc object.Object
+			if len(args) == 3 {
+				acc = args[2]
+			} else {
+				first, ok := it.Next()
+				if !ok {
+					return newArgumentError("`reduce` of empty sequence with no initial value")
+				}
+				if isError(first) {
+					return first
+				}
+				acc = first
+			}
+			res := forEach(it, func(val object.Object) object.Object {
+				acc = in.Apply(args[1], acc, val)
+				if isError(acc) {
+					return acc
+				}
+				return nil
+			})
+			if res != nil {
+				return res
+			}
+			return acc
+		},
+	},
+	"each": {
+		Params: []string{"iterable", "f"},
+		Doc:    "Calls f with every value.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			it, err := iterate(args[0])
+			if err != nil {
+				return err
+			}
+			res := forEach(it, func(val object.Object) object.Object {
+				if res := in.Apply(args[1], val); isError(res) {
+					return res
+				}
+				return nil
+			})
+			if res != nil {
+				return res
+			}
+			return NULL
+		},
+	},
+	"find": {
+		Params: []string{"iterable", "pred"},
+		Doc:    "Returns the first value, for which pred returns a truthy value, or null.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			it, err := iterate(args[0])
+			if err != nil {
+				return err
+			}
+			res := forEach(it, func(val object.Object) object.Object {
+				found := in.Apply(args[1], val)
+				if isError(found) {
+					return found
+				}
+				if isTruthy(found) {
+					return val
+				}
+				return nil
+			})
+			if res != nil {
+				return res
+			}
+			return NULL
+		},
+	},
+	"any": {
+		Params: []string{"iterable", "pred"},
+		Doc:    "Reports whether pred returns a truthy value for any value.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			it, err := iterate(args[0])
+			if err != nil {
+				return err
+			}
+			res := forEach(it, func(val object.Object) object.Object {
+				match := in.Apply(args[1], val)
+				if isError(match) {
+					return match
+				}
+				if isTruthy(match) {
+					return TRUE
+				}
+				return nil
+			})
+			if res != nil {
+				return res
+			}
+			return FALSE
+		},
+	},
+	"all": {
+		Params: []string{"iterable", "pred"},
+		Doc:    "Reports whether pred returns a truthy value for all values.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			it, err := iterate(args[0])
+			if err != nil {
+				return err
+			}
+			res := forEach(it, func(val object.Object) object.Object {
+				match := in.Apply(args[1], val)
+				if isError(match) {
+					return match
+				}
+				if !isTruthy(match) {
+					return FALSE
+				}
+				return nil
+			})
+			if res != nil {
+				return res
+			}
+			return TRUE
+		},
+	},
+	"group_by": {
+		Params: []string{"iterable", "key"},
+		Doc:    "Returns hash from results of key to arrays of values with that result.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			it, err := iterate(args[0])
+			if err != nil {
+				return err
+			}
+			groups := make(map[object.HashKey]object.HashPair)
+			res := forEach(it, func(val object.Object) object.Object {
+				key := in.Apply(args[1], val)
+				if isError(key) {
+					return key
+				}
+				hashable, ok := key.(object.Hashable)
+				if !ok {
+					return newTypeError("unusable as hash key: %s", key.Type())
+				}
+				hashKey := hashable.HashKey()
+				group, ok := groups[hashKey]
+				if !ok {
+					group = object.HashPair{Key: key, Value: &object.Array{}}
+				}
+				arr := group.Value.(*object.Array)
+				arr.Elements = append(arr.Elements, val)
+				groups[hashKey] = group
+				return nil
+			})
+			if res != nil {
+				return res
+			}
+			return &object.Hash{Pairs: groups}
+		},
+	},
+	"wait": {
+		Params: []string{"task"},
+		Doc:    "Waits for the task or an array of tasks and returns the result or an array of results.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			switch arg := args[0].(type) {
+			case *object.Task:
+				return arg.Wait()
+			case *object.Array:
+				results := make([]object.Object, len(arg.Elements))
+				for i, el := range arg.Elements {
+					task, ok := el.(*object.Task)
+					if !ok {
+						return newTypeError("argument to `wait` must be array of TASK, got %s at index %d", el.Type(), i)
+					}
+					results[i] = task.Wait()
+				}
+				for _, res := range results {
+					if isError(res) {
+						return res
+					}
+				}
+				return &object.Array{Elements: results}
+			default:
+				return newTypeError("argument to `wait` must be TASK or ARRAY, got %s", args[0].Type())
+			}
+		},
+	},
+	"channel": {
+		Params: []string{"capacity?"},
+		Doc:    "Returns new channel with the buffer of the capacity, which is 0 by default.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			capacity := int64(0)
+			if len(args) == 1 {
+				c, ok := args[0].(*object.Integer)
+				if !ok {
+					return newTypeError("argument to `channel` must be INTEGER, got %s", args[0].Type())
+				}
+				if c.Value < 0 {
+					return newArgumentError("channel capacity must not be negative, got %d", c.Value)
+				}
+				capacity = c.Value
+			}
+			return object.NewChannel(int(capacity))
+		},
+	},
+	"send": {
+		Params: []string{"ch", "value"},
+		Doc:    "Sends the value to the channel, blocking until there is a receiver or a free buffer slot.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			ch, ok := args[0].(*object.Channel)
+			if !ok {
+				return newTypeError("first argument to `send` must be CHANNEL, got %s", args[0].Type())
+			}
+			if !ch.Send(args[1]) {
+				return newError("send on closed channel")
+			}
+			return NULL
+		},
+	},
+	"recv": {
+		Params: []string{"ch"},
+		Doc:    "Receives value from the channel. Returns null, when the channel is closed.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			ch, ok := args[0].(*object.Channel)
+			if !ok {
+				return newTypeError("argument to `recv` must be CHANNEL, got %s", args[0].Type())
+			}
+			val, ok := <-ch.Chan
+			if !ok {
+				return NULL
+			}
+			return val
+		},
+	},
+	"close": {
+		Params: []string{"ch"},
+		Doc:    "Closes the channel.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			ch, ok := args[0].(*object.Channel)
+			if !ok {
+				return newTypeError("argument to `close` must be CHANNEL, got %s", args[0].Type())
+			}
+			if !ch.Close() {
+				return newError("close of closed channel")
+			}
+			return NULL
+		},
+	},
+	"select": {
+		Params: []string{"cases"},
+		Doc:    "Waits until one of the cases can proceed and returns [index of the case, received value]. Case is a channel to receive from or [channel, value] to send the value.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			cases, ok := args[0].(*object.Array)
+			if !ok {
+				return newTypeError("argument to `select` must be ARRAY, got %s", args[0].Type())
+			}
+			return selectChannels(cases.Elements)
+		},
+	},
 	"puts": {
-		Fn: func(args ...object.Object) object.Object {
+		Params: []string{"values..."},
+		Doc:    "Writes every value on a separate line.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
 			for _, arg := range args {
-				fmt.Println(arg.Inspect())
+				fmt.Fprintln(in.Output(), arg.Inspect())
 			}
 			return NULL
 		},
 	},
 }
+
+func init() {
+	nameBuiltins(builtins)
+}
+
+// registerBuiltins adds builtins of a module to the global table.
+// It is meant to be called from init functions of module files.
+func registerBuiltins(module map[string]*object.Builtin) {
+	for name, b := range nameBuiltins(module) {
+		if _, ok := builtins[name]; ok {
+			panic("builtin " + name + " is already registered")
+		}
+		builtins[name] = b
+	}
+}
+
+// nameBuiltins sets names of builtins to their keys.
+func nameBuiltins(m map[string]*object.Builtin) map[string]*object.Builtin {
+	for name, b := range m {
+		b.Name = name
+	}
+	return m
+}
+
+// newModule creates module and names its builtin members, e.g. math.sqrt.
+func newModule(name string, members map[string]object.Object) *object.Module {
+	for member, obj := range members {
+		if b, ok := obj.(*object.Builtin); ok {
+			b.Name = name + "." + member
+		}
+	}
+	return &object.Module{Name: name, Members: members}
+}
+
+// disabledBuiltins returns stubs of builtins of a capability, that fail with permission error.
+// Stubs are registered globally, so scripts get a clear error instead of "identifier not found",
+// when the capability is not granted, and can still look up help of the builtins.
+func disabledBuiltins(capability string, m map[string]*object.Builtin) map[string]*object.Builtin {
+	stubs := make(map[string]*object.Builtin, len(m))
+	for name, b := range nameBuiltins(m) {
+		stubs[name] = disabledBuiltin(capability, b)
+	}
+	return stubs
+}
+
+// disabledModule returns module with stubs of builtin members, see disabledBuiltins.
+func disabledModule(capability string, module *object.Module) *object.Module {
+	members := make(map[string]object.Object, len(module.Members))
+	for name, obj := range module.Members {
+		if b, ok := obj.(*object.Builtin); ok {
+			members[name] = disabledBuiltin(capability, b)
+		}
+	}
+	return &object.Module{Name: module.Name, Members: members}
+}
+
+func disabledBuiltin(capability string, b *object.Builtin) *object.Builtin {
+	name := b.Name
+	return &object.Builtin{
+		Name:   name,
+		Params: b.Params,
+		Doc:    b.Doc,
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			return newErrorOfKind(object.PERMISSION_ERROR, "%s: %s access is not enabled", name, capability)
+		},
+	}
+}
+
+// checkArity reports error, if number of arguments doesn't match parameters of the builtin.
+func checkArity(b *object.Builtin, n int) *object.Error {
+	min, max := b.Arity()
+	switch {
+	case max < 0 && n < min:
+		return newArgumentError("wrong number of arguments. got=%d, want at least %d", n, min)
+	case max < 0 || (n >= min && n <= max):
+		return nil
+	case min == max:
+		return newArgumentError("wrong number of arguments. got=%d, want=%d", n, min)
+	}
+	want := make([]string, 0, max-min+1)
+	for i := min; i <= max; i++ {
+		want = append(want, strconv.Itoa(i))
+	}
+	return newArgumentError("wrong number of arguments. got=%d, want=%s or %s",
+		n, strings.Join(want[:len(want)-1], ", "), want[len(want)-1])
+}
+
+// modules are namespaces of builtins accessed with dot, e.g. math.sqrt.
+var modules = map[string]*object.Module{}
+
+// registerModule adds module to the global table.
+// It is meant to be called from init functions of module files.
+func registerModule(module *object.Module) {
+	if _, ok := modules[module.Name]; ok {
+		panic("module " + module.Name + " is already registered")
+	}
+	modules[module.Name] = module
+}
+
+// forEach calls f for every value of the iterator, until f returns non nil object,
+// which is then returned. Error produced by the iterator is returned as is.
+func forEach(it *object.Iterator, f func(val object.Object) object.Object) object.Object {
+	for {
+		val, ok := it.Next()
+		if !ok {
+			return nil
+		}
+		if isError(val) {
+			return val
+		}
+		if res := f(val); res != nil {
+			return res
+		}
+	}
+}
+
+// collect reads at most limit values from iterator into array.
+// Negative limit means no limit.
+func collect(it *object.Iterator, limit int) object.Object {
+	elements := []object.Object{}
+	for limit < 0 || len(elements) < limit {
+		val, ok := it.Next()
+		if !ok {
+			break
+		}
+		if isError(val) {
+			return val
+		}
+		elements = append(elements, val)
+	}
+	return &object.Array{Elements: elements}
+}
+
+// selectChannels waits until one of the cases can proceed.
+// Case is either a channel to receive from or [channel, value] array to send value.
+// Result is [index of the case, received value], value is null for send cases
+// and for receives from closed channels.
+func selectChannels(cases []object.Object) object.Object {
+	if len(cases) == 0 {
+		return newArgumentError("`select` expects at least one case")
+	}
+	selectCases := make([]reflect.SelectCase, len(cases))
+	sendTo := make([]*object.Channel, len(cases))
+	for i, c := range cases {
+		switch c := c.(type) {
+		case *object.Channel:
+			selectCases[i] = reflect.SelectCase{
+				Dir:  reflect.SelectRecv,
+				Chan: reflect.ValueOf(c.Chan),
+			}
+		case *object.Array:
+			var ch *object.Channel
+			if len(c.Elements) == 2 {
+				ch, _ = c.Elements[0].(*object.Channel)
+			}
+			if ch == nil {
+				return newTypeError("send case of `select` must be [CHANNEL, value], got %s at index %d", c.Inspect(), i)
+			}
+			selectCases[i] = reflect.SelectCase{
+				Dir:  reflect.SelectSend,
+				Chan: reflect.ValueOf(ch.Chan),
+				Send: reflect.ValueOf(&c.Elements[1]).Elem(),
+			}
+			sendTo[i] = ch
+		default:
+			return newTypeError("case of `select` must be CHANNEL or ARRAY, got %s at index %d", c.Type(), i)
+		}
+	}
+
+	chosen, val, ok, closedErr := trySelect(selectCases)
+	if closedErr {
+		return newError("send on closed channel")
+	}
+	result := object.Object(NULL)
+	if sendTo[chosen] == nil && ok {
+		result = val.Interface().(object.Object)
+	}
+	return &object.Array{Elements: []object.Object{&object.Integer{Value: int64(chosen)}, result}}
+}
+
+func trySelect(cases []reflect.SelectCase) (chosen int, val reflect.Value, ok bool, sendOnClosed bool) {
+	defer func() {
+		if recover() != nil {
+			sendOnClosed = true
+		}
+	}()
+	chosen, val, ok = reflect.Select(cases)
+	return chosen, val, ok, false
+}
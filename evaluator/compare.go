@@ -0,0 +1,104 @@
+package evaluator
+
+import (
+	"time"
+
+	"github.com/pechorka/plang/object"
+)
+
+// compareObjects returns -1, 0 or 1 if a is less, equal or greater than b.
+// ok is false if objects are not comparable with each other.
+func compareObjects(a, b object.Object) (result int, ok bool) {
+	switch a := a.(type) {
+	case *object.Integer:
+		switch b := b.(type) {
+		case *object.Integer:
+			return compareInts(a.Value, b.Value), true
+		case *object.Float:
+			return compareFloats(float64(a.Value), b.Value), true
+		}
+		return 0, false
+	case *object.Float:
+		bv, ok := toFloat(b)
+		if !ok {
+			return 0, false
+		}
+		return compareFloats(a.Value, bv), true
+	case *object.String:
+		b, ok := b.(*object.String)
+		if !ok {
+			return 0, false
+		}
+		return compareStrings(a.Value, b.Value), true
+	case *object.Time:
+		b, ok := b.(*object.Time)
+		if !ok {
+			return 0, false
+		}
+		return compareTimes(a.Value, b.Value), true
+	case *object.Duration:
+		b, ok := b.(*object.Duration)
+		if !ok {
+			return 0, false
+		}
+		return compareInts(int64(a.Value), int64(b.Value)), true
+	case *object.Boolean:
+		b, ok := b.(*object.Boolean)
+		if !ok {
+			return 0, false
+		}
+		return compareBools(a.Value, b.Value), true
+	}
+	return 0, false
+}
+
+func compareInts(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareFloats(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareTimes(a, b time.Time) int {
+	switch {
+	case a.Before(b):
+		return -1
+	case a.After(b):
+		return 1
+	}
+	return 0
+}
+
+func compareStrings(a, b string) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// false is less than true
+func compareBools(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case !a:
+		return -1
+	}
+	return 1
+}
@@ -2,9 +2,16 @@ package evaluator
 
 import (
 	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
 
 	"github.com/pechorka/plang/ast"
 	"github.com/pechorka/plang/object"
+	"github.com/pechorka/plang/token"
 )
 
 var (
@@ -13,95 +20,177 @@ var (
 	NULL  = &object.Null{}
 )
 
+// Evaluator evaluates programs.
+// Capabilities, that let scripts reach outside of the interpreter,
+// like file system access, are disabled unless granted with options.
+type Evaluator struct {
+	// builtins and modules of this evaluator, that take precedence over the global ones.
+	// Options use them to replace stubs of disabled capabilities.
+	builtins map[string]*object.Builtin
+	modules  map[string]*object.Module
+	out      *syncWriter
+	rand     *rand.Rand
+}
+
+// Option configures Evaluator.
+type Option func(e *Evaluator)
+
+func New(opts ...Option) *Evaluator {
+	e := &Evaluator{
+		builtins: make(map[string]*object.Builtin),
+		modules:  make(map[string]*object.Module),
+		out:      &syncWriter{w: os.Stdout},
+		rand:     newRand(time.Now().UnixNano()),
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// WithOutput sets writer for output builtins. Default is os.Stdout.
+func WithOutput(w io.Writer) Option {
+	return func(e *Evaluator) {
+		e.out = &syncWriter{w: w}
+	}
+}
+
+var defaultEvaluator = New()
+
+// Eval evaluates node with evaluator, that has no capabilities granted.
 func Eval(node ast.Node, env *object.Environment) object.Object {
+	return defaultEvaluator.Eval(node, env)
+}
+
+func (e *Evaluator) Eval(node ast.Node, env *object.Environment) object.Object {
+	result := e.eval(node, env)
+	if err, ok := result.(*object.Error); ok && !err.Pos.IsValid() {
+		// the innermost node, that produced the error, is the most precise location
+		err.Pos = node.Pos()
+	}
+	return result
+}
+
+func (e *Evaluator) eval(node ast.Node, env *object.Environment) object.Object {
 	switch n := node.(type) {
 	case *ast.Program:
-		return evalProgram(n, env)
+		return e.evalProgram(n, env)
 	case *ast.BlockStatement:
-		return evalBlockStatement(n, env)
+		return e.evalBlockStatement(n, env)
 	case *ast.ExpressionStatement:
-		return Eval(n.Expression, env)
+		return e.Eval(n.Expression, env)
 	case *ast.PrefixExpression:
-		right := Eval(n.Right, env)
+		right := e.Eval(n.Right, env)
 		if isError(right) {
 			return right
 		}
 		return evalPrefixExpression(n.Operator, right)
 	case *ast.InfixExpression:
-		left := Eval(n.Left, env)
+		left := e.Eval(n.Left, env)
 		if isError(left) {
 			return left
 		}
-		right := Eval(n.Right, env)
+		right := e.Eval(n.Right, env)
 		if isError(right) {
 			return right
 		}
 		return evalInfixExpression(n.Operator, left, right)
 	case *ast.IfExpression:
-		return evalIfExpression(n, env)
+		return e.evalIfExpression(n, env)
 	case *ast.ReturnStatement:
-		val := Eval(n.Value, env)
+		val := e.Eval(n.Value, env)
 		if isError(val) {
 			return val
 		}
 		return &object.ReturnValue{Value: val}
 	case *ast.LetStatement:
-		val := Eval(n.Value, env)
+		val := e.Eval(n.Value, env)
 		if isError(val) {
 			return val
 		}
 		return env.Set(n.Name.Value, val)
 	case *ast.Identifier:
-		return evalIdentifier(n, env)
+		return e.evalIdentifier(n, env)
 	case *ast.FnExpression:
 		return &object.Function{
-			Parameters: n.Params,
-			Body:       n.Body,
-			Env:        env,
+			Parameters:  n.Params,
+			Body:        n.Body,
+			Env:         env,
+			IsGenerator: n.IsGenerator,
 		}
 	case *ast.CallExpression:
-		function := Eval(n.Function, env)
+		function := e.Eval(n.Function, env)
 		if isError(function) {
 			return function
 		}
-		args := evalExpressions(n.Arguments, env)
+		args := e.evalExpressions(n.Arguments, env)
 		if len(args) == 1 && isError(args[0]) {
 			return args[0]
 		}
-		return applyFunction(function, args)
+		return anchorAt(e.applyFunction(function, args), n.Pos())
 	case *ast.IntegerLiteral:
 		return &object.Integer{Value: n.Value}
+	case *ast.FloatLiteral:
+		return &object.Float{Value: n.Value}
 	case *ast.Boolean:
 		return boolToBooleanObject(n.Value)
 	case *ast.StringLiteral:
 		return &object.String{Value: n.Value}
 	case *ast.ArrayLiteral:
-		elems := evalExpressions(n.Elements, env)
+		elems := e.evalExpressions(n.Elements, env)
 		if len(elems) == 1 && isError(elems[0]) {
 			return elems[0]
 		}
 		return &object.Array{Elements: elems}
 	case *ast.HashLiteral:
-		return evalHashLiteral(n, env)
+		return e.evalHashLiteral(n, env)
 	case *ast.IndexExpression:
-		left := Eval(n.Left, env)
+		left := e.Eval(n.Left, env)
 		if isError(left) {
 			return left
 		}
-		index := Eval(n.Index, env)
+		index := e.Eval(n.Index, env)
 		if isError(index) {
 			return index
 		}
 		return evalIndexExpression(left, index)
+	case *ast.SliceExpression:
+		return e.evalSliceExpression(n, env)
+	case *ast.StructStatement:
+		return evalStructStatement(n, env)
+	case *ast.ThrowStatement:
+		val := e.Eval(n.Value, env)
+		if isError(val) {
+			return val
+		}
+		return newThrownError(val)
+	case *ast.TryExpression:
+		return e.evalTryExpression(n, env)
+	case *ast.YieldStatement:
+		val := e.Eval(n.Value, env)
+		if isError(val) {
+			return val
+		}
+		return yield(val, env)
+	case *ast.ForExpression:
+		return e.evalForExpression(n, env)
+	case *ast.SpawnExpression:
+		return e.evalSpawnExpression(n, env)
+	case *ast.MemberExpression:
+		obj := e.Eval(n.Object, env)
+		if isError(obj) {
+			return obj
+		}
+		return evalMemberExpression(obj, n.Member.Value)
 	}
 
 	return NULL
 }
 
-func evalProgram(program *ast.Program, env *object.Environment) object.Object {
+func (e *Evaluator) evalProgram(program *ast.Program, env *object.Environment) object.Object {
 	var result object.Object
 	for _, statement := range program.Statements {
-		result = Eval(statement, env)
+		result = e.Eval(statement, env)
 		switch res := result.(type) {
 		case *object.ReturnValue:
 			return res.Value
@@ -112,10 +201,10 @@ func evalProgram(program *ast.Program, env *object.Environment) object.Object {
 	return result
 }
 
-func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
+func (e *Evaluator) evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
 	var result object.Object
 	for _, statement := range block.Statements {
-		result = Eval(statement, env)
+		result = e.Eval(statement, env)
 		if result != nil {
 			switch result.Type() {
 			case object.RETURN_VALUE_OBJ, object.ERROR_OBJ:
@@ -133,7 +222,7 @@ func evalPrefixExpression(operator string, right object.Object) object.Object {
 	case "-":
 		return evalMinusExpression(right)
 	default:
-		return newError("unknown operator: %s%s", operator, right.Type())
+		return newTypeError("unknown operator: %s%s", operator, right.Type())
 	}
 }
 
@@ -141,30 +230,38 @@ func evalInfixExpression(operator string, left, right object.Object) object.Obje
 	switch {
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
 		return evalIntegerInfixExpression(operator, left, right)
+	case isNumber(left) && isNumber(right):
+		return evalFloatInfixExpression(operator, left, right)
 	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
 		return evalStringInfixExpression(operator, left, right)
+	case operator == "*" && left.Type() == object.STRING_OBJ && right.Type() == object.INTEGER_OBJ:
+		return repeatString(left.(*object.String), right.(*object.Integer))
+	case operator == "*" && left.Type() == object.INTEGER_OBJ && right.Type() == object.STRING_OBJ:
+		return repeatString(right.(*object.String), left.(*object.Integer))
+	case isTemporal(left) || isTemporal(right):
+		return evalTimeInfixExpression(operator, left, right)
 	case operator == "==":
 		return boolToBooleanObject(left == right)
 	case operator == "!=":
 		return boolToBooleanObject(left != right)
 	case left.Type() != right.Type():
-		return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
+		return newTypeError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
 	default:
-		return newError("unknown operator: %s %s %s",
+		return newTypeError("unknown operator: %s %s %s",
 			left.Type(), operator, right.Type())
 	}
 }
 
-func evalIfExpression(ifExpr *ast.IfExpression, env *object.Environment) object.Object {
-	cond := Eval(ifExpr.Condition, env)
+func (e *Evaluator) evalIfExpression(ifExpr *ast.IfExpression, env *object.Environment) object.Object {
+	cond := e.Eval(ifExpr.Condition, env)
 	if isError(cond) {
 		return cond
 	}
 	if isTruthy(cond) {
-		return Eval(ifExpr.Then, env)
+		return e.Eval(ifExpr.Then, env)
 	}
 	if ifExpr.Else != nil {
-		return Eval(ifExpr.Else, env)
+		return e.Eval(ifExpr.Else, env)
 	}
 
 	return NULL
@@ -184,27 +281,42 @@ func evalBangExpression(right object.Object) object.Object {
 }
 
 func evalMinusExpression(right object.Object) object.Object {
-	if right.Type() != object.INTEGER_OBJ {
-		return newError("unknown operator: -%s", right.Type())
+	switch right := right.(type) {
+	case *object.Integer:
+		if right.Value == math.MinInt64 {
+			return newError("integer overflow: -(%d)", right.Value)
+		}
+		return &object.Integer{Value: -right.Value}
+	case *object.Float:
+		return &object.Float{Value: -right.Value}
+	case *object.Duration:
+		return &object.Duration{Value: -right.Value}
+	default:
+		return newTypeError("unknown operator: -%s", right.Type())
 	}
-
-	value := right.(*object.Integer).Value
-	return &object.Integer{Value: -value}
 }
 
 func evalIntegerInfixExpression(operator string, left, right object.Object) object.Object {
 	leftValue := left.(*object.Integer).Value
 	rightValue := right.(*object.Integer).Value
 
+	// overflow is an error like in math module rather than a silent wrap around
+	var (
+		res int64
+		ok  bool
+	)
 	switch operator {
 	case "+":
-		return &object.Integer{Value: leftValue + rightValue}
+		res, ok = addInt(leftValue, rightValue)
 	case "-":
-		return &object.Integer{Value: leftValue - rightValue}
+		res, ok = subInt(leftValue, rightValue)
 	case "*":
-		return &object.Integer{Value: leftValue * rightValue}
+		res, ok = mulInt(leftValue, rightValue)
 	case "/":
-		return &object.Integer{Value: leftValue / rightValue}
+		if rightValue == 0 {
+			return newError("division by zero")
+		}
+		res, ok = leftValue/rightValue, !(leftValue == math.MinInt64 && rightValue == -1)
 	// comparison
 	case "<":
 		return boolToBooleanObject(leftValue < rightValue)
@@ -215,9 +327,73 @@ func evalIntegerInfixExpression(operator string, left, right object.Object) obje
 	case "!=":
 		return boolToBooleanObject(leftValue != rightValue)
 	default:
-		return newError("unknown operator: %s %s %s",
+		return newTypeError("unknown operator: %s %s %s",
 			left.Type(), operator, right.Type())
 	}
+	if !ok {
+		return newError("integer overflow: %d %s %d", leftValue, operator, rightValue)
+	}
+	return &object.Integer{Value: res}
+}
+
+// evalFloatInfixExpression handles floats and mixed integer and float operands.
+// Integer operand is converted to float.
+func evalFloatInfixExpression(operator string, left, right object.Object) object.Object {
+	leftValue, _ := toFloat(left)
+	rightValue, _ := toFloat(right)
+
+	switch operator {
+	case "+":
+		return &object.Float{Value: leftValue + rightValue}
+	case "-":
+		return &object.Float{Value: leftValue - rightValue}
+	case "*":
+		return &object.Float{Value: leftValue * rightValue}
+	case "/":
+		if rightValue == 0 {
+			return newError("division by zero")
+		}
+		return &object.Float{Value: leftValue / rightValue}
+	// comparison
+	case "<":
+		return boolToBooleanObject(leftValue < rightValue)
+	case ">":
+		return boolToBooleanObject(leftValue > rightValue)
+	case "==":
+		return boolToBooleanObject(numbersEqual(left, right))
+	case "!=":
+		return boolToBooleanObject(!numbersEqual(left, right))
+	default:
+		return newTypeError("unknown operator: %s %s %s",
+			left.Type(), operator, right.Type())
+	}
+}
+
+// numbersEqual compares integer and float exactly, not as floats,
+// so equal numbers are equal keys of hashes, e.g. 2^53 + 1 is not equal to 2.0^53.
+func numbersEqual(left, right object.Object) bool {
+	l, lok := left.(*object.Float)
+	r, rok := right.(*object.Float)
+	if lok && rok {
+		return l.Value == r.Value
+	}
+	return left.(object.Hashable).HashKey() == right.(object.Hashable).HashKey()
+}
+
+func isNumber(obj object.Object) bool {
+	_, ok := toFloat(obj)
+	return ok
+}
+
+// toFloat converts integer or float to float64.
+func toFloat(obj object.Object) (float64, bool) {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return float64(obj.Value), true
+	case *object.Float:
+		return obj.Value, true
+	}
+	return 0, false
 }
 
 func evalStringInfixExpression(operator string, left, right object.Object) object.Object {
@@ -227,28 +403,48 @@ func evalStringInfixExpression(operator string, left, right object.Object) objec
 	switch operator {
 	case "+":
 		return &object.String{Value: leftValue + rightValue}
+	case "<":
+		return boolToBooleanObject(leftValue < rightValue)
+	case ">":
+		return boolToBooleanObject(leftValue > rightValue)
+	case "==":
+		return boolToBooleanObject(leftValue == rightValue)
+	case "!=":
+		return boolToBooleanObject(leftValue != rightValue)
 	default:
-		return newError("unknown operator: %s %s %s",
+		return newTypeError("unknown operator: %s %s %s",
 			left.Type(), operator, right.Type())
 	}
 }
 
-func evalIdentifier(idenExpr *ast.Identifier, env *object.Environment) object.Object {
+func (e *Evaluator) evalIdentifier(idenExpr *ast.Identifier, env *object.Environment) object.Object {
 	if obj, ok := env.Get(idenExpr.Value); ok {
 		return obj
 	}
 
+	if buitin, ok := e.builtins[idenExpr.Value]; ok {
+		return buitin
+	}
+
 	if buitin, ok := builtins[idenExpr.Value]; ok {
 		return buitin
 	}
 
-	return newError("identifier not found: %s", idenExpr.Value)
+	if module, ok := e.modules[idenExpr.Value]; ok {
+		return module
+	}
+
+	if module, ok := modules[idenExpr.Value]; ok {
+		return module
+	}
+
+	return newNameError("identifier not found: %s", idenExpr.Value)
 }
 
-func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
+func (e *Evaluator) evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
 	var result []object.Object
-	for _, e := range exps {
-		evaluated := Eval(e, env)
+	for _, exp := range exps {
+		evaluated := e.Eval(exp, env)
 		if isError(evaluated) {
 			return []object.Object{evaluated}
 		}
@@ -257,21 +453,21 @@ func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Ob
 	return result
 }
 
-func evalHashLiteral(n *ast.HashLiteral, env *object.Environment) object.Object {
+func (e *Evaluator) evalHashLiteral(n *ast.HashLiteral, env *object.Environment) object.Object {
 	pairs := make(map[object.HashKey]object.HashPair)
 
 	for k, v := range n.Pairs {
-		key := Eval(k, env)
+		key := e.Eval(k, env)
 		if isError(key) {
 			return key
 		}
 
 		hashable, ok := key.(object.Hashable)
 		if !ok {
-			return newError("unusable as hash key: %s", key.Type())
+			return newTypeError("unusable as hash key: %s", key.Type())
 		}
 
-		value := Eval(v, env)
+		value := e.Eval(v, env)
 		if isError(value) {
 			return value
 		}
@@ -289,28 +485,114 @@ func evalIndexExpression(left, index object.Object) object.Object {
 	switch {
 	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
 		return evalArrayIndexExpression(left, index)
+	case left.Type() == object.STRING_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalStringIndexExpression(left, index)
 	case left.Type() == object.HASH_OBJ:
 		return evalHashIndexExpression(left, index)
 	default:
-		return newError("index operator not supported: %s", left.Type())
+		return newTypeError("index operator not supported: %s", left.Type())
 	}
 }
 
 func evalArrayIndexExpression(array, index object.Object) object.Object {
 	arrayObject := array.(*object.Array)
-	idx := index.(*object.Integer).Value
-	max := int64(len(arrayObject.Elements) - 1)
-	if idx < 0 || idx > max {
+	idx, ok := normalizeIndex(index.(*object.Integer).Value, len(arrayObject.Elements))
+	if !ok {
 		return NULL
 	}
 	return arrayObject.Elements[idx]
 }
 
+// evalStringIndexExpression returns rune at index as a string
+func evalStringIndexExpression(str, index object.Object) object.Object {
+	runes := []rune(str.(*object.String).Value)
+	idx, ok := normalizeIndex(index.(*object.Integer).Value, len(runes))
+	if !ok {
+		return NULL
+	}
+	return &object.String{Value: string(runes[idx])}
+}
+
+// normalizeIndex converts negative index to the index from the start
+// and reports whether index is in range.
+func normalizeIndex(idx int64, length int) (int, bool) {
+	if idx < 0 {
+		idx += int64(length)
+	}
+	if idx < 0 || idx >= int64(length) {
+		return 0, false
+	}
+	return int(idx), true
+}
+
+func (e *Evaluator) evalSliceExpression(n *ast.SliceExpression, env *object.Environment) object.Object {
+	left := e.Eval(n.Left, env)
+	if isError(left) {
+		return left
+	}
+
+	var bounds [2]*int64
+	for i, bound := range []ast.Expression{n.Start, n.End} {
+		if bound == nil {
+			continue
+		}
+		val := e.Eval(bound, env)
+		if isError(val) {
+			return val
+		}
+		integer, ok := val.(*object.Integer)
+		if !ok {
+			return newTypeError("slice bounds must be INTEGER, got %s", val.Type())
+		}
+		bounds[i] = &integer.Value
+	}
+
+	switch left := left.(type) {
+	case *object.Array:
+		start, end := sliceBounds(bounds[0], bounds[1], len(left.Elements))
+		elements := make([]object.Object, end-start)
+		copy(elements, left.Elements[start:end])
+		return &object.Array{Elements: elements}
+	case *object.String:
+		runes := []rune(left.Value)
+		start, end := sliceBounds(bounds[0], bounds[1], len(runes))
+		return &object.String{Value: string(runes[start:end])}
+	default:
+		return newTypeError("slice operator not supported: %s", left.Type())
+	}
+}
+
+// sliceBounds converts optional and possibly negative bounds to the valid range.
+// Bounds out of range are clamped, so slicing never fails.
+func sliceBounds(start, end *int64, length int) (int, int) {
+	clamp := func(bound *int64, def int) int {
+		if bound == nil {
+			return def
+		}
+		b := *bound
+		if b < 0 {
+			b += int64(length)
+		}
+		if b < 0 {
+			return 0
+		}
+		if b > int64(length) {
+			return length
+		}
+		return int(b)
+	}
+	s, e := clamp(start, 0), clamp(end, length)
+	if s > e {
+		return s, s
+	}
+	return s, e
+}
+
 func evalHashIndexExpression(hash, index object.Object) object.Object {
 	hashObject := hash.(*object.Hash)
 	hashable, ok := index.(object.Hashable)
 	if !ok {
-		return newError("unusable as hash key: %s", index.Type())
+		return newTypeError("unusable as hash key: %s", index.Type())
 	}
 
 	val, ok := hashObject.Pairs[hashable.HashKey()]
@@ -321,18 +603,295 @@ func evalHashIndexExpression(hash, index object.Object) object.Object {
 	return val.Value
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
+func (e *Evaluator) evalForExpression(n *ast.ForExpression, env *object.Environment) object.Object {
+	iterable := e.Eval(n.Iterable, env)
+	if isError(iterable) {
+		return iterable
+	}
+
+	it, err := iterate(iterable)
+	if err != nil {
+		return err
+	}
+
+	for {
+		val, ok := it.Next()
+		if !ok {
+			return NULL
+		}
+		if isError(val) {
+			return val
+		}
+		// every iteration has its own variable, so closures of the body capture its value
+		result := e.Eval(n.Body, object.NewLoopEnvironment(env, n.Variable.Value, val))
+		if result != nil {
+			switch result.Type() {
+			case object.RETURN_VALUE_OBJ, object.ERROR_OBJ:
+				return result
+			}
+		}
+	}
+}
+
+func iterate(obj object.Object) (*object.Iterator, *object.Error) {
+	iterable, ok := obj.(object.Iterable)
+	if !ok {
+		return nil, newTypeError("%s is not iterable", obj.Type())
+	}
+	return iterable.Iter(), nil
+}
+
+// evalSpawnExpression evaluates function and its arguments on the current goroutine
+// and then applies the function on a new one.
+func (e *Evaluator) evalSpawnExpression(n *ast.SpawnExpression, env *object.Environment) object.Object {
+	var (
+		function object.Object
+		args     []object.Object
+	)
+	if call, ok := n.Call.(*ast.CallExpression); ok {
+		function = e.Eval(call.Function, env)
+		if isError(function) {
+			return function
+		}
+		args = e.evalExpressions(call.Arguments, env)
+		if len(args) == 1 && isError(args[0]) {
+			return args[0]
+		}
+	} else {
+		function = e.Eval(n.Call, env)
+		if isError(function) {
+			return function
+		}
+	}
+
+	switch function.(type) {
+	case *object.Function, *object.Builtin, *object.StructType:
+	default:
+		return newTypeError("not a function: %s", function.Type())
+	}
+
+	task := object.NewTask()
+	go func() {
+		task.Finish(e.applyFunction(function, args))
+	}()
+	return task
+}
+
+func (e *Evaluator) evalTryExpression(n *ast.TryExpression, env *object.Environment) object.Object {
+	result := e.Eval(n.Block, env)
+
+	if err, ok := result.(*object.Error); ok && n.Catch != nil && !isExit(err) && !isGeneratorClosed(err) {
+		catchEnv := object.NewEnclosedEnvironment(env)
+		catchEnv.Set(n.CatchParam.Value, caughtValue(err))
+		result = e.Eval(n.Catch, catchEnv)
+	}
+
+	if n.Finally != nil {
+		finally := e.Eval(n.Finally, env)
+		if finally != nil {
+			// error or return from finally block take precedence over the result of try
+			switch finally.Type() {
+			case object.RETURN_VALUE_OBJ, object.ERROR_OBJ:
+				return finally
+			}
+		}
+	}
+
+	return result
+}
+
+// caughtValue converts error to the value, that is bound to the catch variable.
+// Thrown values are caught as is, runtime errors are converted to hash
+// with message, kind, line and column keys.
+func caughtValue(err *object.Error) object.Object {
+	if err.Value != nil {
+		return err.Value
+	}
+	pairs := make(map[object.HashKey]object.HashPair)
+	set := func(key string, val object.Object) {
+		k := &object.String{Value: key}
+		pairs[k.HashKey()] = object.HashPair{Key: k, Value: val}
+	}
+	set("message", &object.String{Value: err.Message})
+	set("kind", &object.String{Value: err.Kind})
+	set("line", &object.Integer{Value: int64(err.Pos.Line)})
+	set("column", &object.Integer{Value: int64(err.Pos.Column)})
+	return &object.Hash{Pairs: pairs}
+}
+
+func newThrownError(val object.Object) *object.Error {
+	err := &object.Error{
+		Kind:    object.THROWN_ERROR,
+		Message: val.Inspect(),
+		Value:   val,
+	}
+	// rethrown runtime errors keep their message and kind
+	if hash, ok := val.(*object.Hash); ok {
+		if msg, ok := hashField(hash, "message"); ok {
+			if msg, ok := msg.(*object.String); ok {
+				err.Message = msg.Value
+			}
+		}
+		// exit can't be thrown, only exit builtin creates it
+		if kind, ok := hashField(hash, "kind"); ok {
+			if kind, ok := kind.(*object.String); ok && kind.Value != object.EXIT {
+				err.Kind = kind.Value
+			}
+		}
+	}
+	return err
+}
+
+func isExit(err *object.Error) bool {
+	_, ok := err.ExitCode()
+	return ok
+}
+
+// hashField returns value of the hash under the string key.
+func hashField(hash *object.Hash, key string) (object.Object, bool) {
+	pair, ok := hash.Pairs[(&object.String{Value: key}).HashKey()]
+	if !ok {
+		return nil, false
+	}
+	return pair.Value, true
+}
+
+func evalStructStatement(n *ast.StructStatement, env *object.Environment) object.Object {
+	st := &object.StructType{
+		Name:    n.Name.Value,
+		Methods: make(map[string]*object.Function, len(n.Methods)),
+	}
+	for _, f := range n.Fields {
+		st.Fields = append(st.Fields, f.Value)
+	}
+	for _, m := range n.Methods {
+		st.Methods[m.Name.Value] = &object.Function{
+			Parameters:  m.Fn.Params,
+			Body:        m.Fn.Body,
+			Env:         env,
+			IsGenerator: m.Fn.IsGenerator,
+		}
+	}
+	return env.Set(st.Name, st)
+}
+
+func evalMemberExpression(obj object.Object, name string) object.Object {
+	if module, ok := obj.(*object.Module); ok {
+		if val, ok := module.Members[name]; ok {
+			return val
+		}
+		return newNameError("unknown member %s of module %s", name, module.Name)
+	}
+
+	instance, ok := obj.(*object.StructInstance)
+	if !ok {
+		return newTypeError("member access not supported: %s", obj.Type())
+	}
+
+	if val, ok := instance.Fields[name]; ok {
+		return val
+	}
+
+	if method, ok := instance.Struct.Methods[name]; ok {
+		return bindMethod(method, instance)
+	}
+
+	return newNameError("unknown field %s on %s", name, instance.Struct.Name)
+}
+
+// bindMethod returns copy of the method, which has self bound to the receiver.
+func bindMethod(method *object.Function, receiver *object.StructInstance) *object.Function {
+	env := object.NewEnclosedEnvironment(method.Env)
+	env.Set("self", receiver)
+	return &object.Function{
+		Parameters:  method.Parameters,
+		Body:        method.Body,
+		Env:         env,
+		IsGenerator: method.IsGenerator,
+	}
+}
+
+func newStructInstance(st *object.StructType, args []object.Object) object.Object {
+	if len(args) != len(st.Fields) {
+		return newArgumentError("wrong number of arguments to %s. got=%d, want=%d",
+			st.Name, len(args), len(st.Fields))
+	}
+	fields := make(map[string]object.Object, len(args))
+	for i, f := range st.Fields {
+		fields[f] = args[i]
+	}
+	return &object.StructInstance{Struct: st, Fields: fields}
+}
+
+func (e *Evaluator) applyFunction(fn object.Object, args []object.Object) object.Object {
 	switch fn := fn.(type) {
 	case *object.Function:
+		if len(args) != len(fn.Parameters) {
+			return newArgumentError("wrong number of arguments. got=%d, want=%d",
+				len(args), len(fn.Parameters))
+		}
+		if fn.IsGenerator {
+			return e.newGenerator(fn, args)
+		}
 		extendedEnv := extendFunctionEnv(fn, args)
-		evaluated := Eval(fn.Body, extendedEnv)
+		evaluated := e.Eval(fn.Body, extendedEnv)
 		return unwrapReturnValue(evaluated)
 	case *object.Builtin:
-		return fn.Fn(args...)
+		if err := checkArity(fn, len(args)); err != nil {
+			return err
+		}
+		return fn.Fn(e, args...)
+	case *object.StructType:
+		return newStructInstance(fn, args)
+	default:
+		return newTypeError("not a function: %s", fn.Type())
+	}
+}
+
+// anchorAt moves error, that happened in other source than the call, e.g. in the prelude,
+// to the call, so it is shown with the code of the caller. The first position
+// in other source is kept as origin of the error, until the error gets back to its source.
+func anchorAt(result object.Object, call token.Position) object.Object {
+	err, ok := result.(*object.Error)
+	if !ok || !err.Pos.IsValid() || err.Pos.Source == call.Source {
+		return result
+	}
+	switch {
+	case err.Origin.IsValid() && err.Origin.Source == call.Source:
+		err.Pos, err.Origin = err.Origin, token.Position{}
+	case err.Origin.IsValid():
+		err.Pos = call
 	default:
-		return newError("not a function: %s", fn.Type())
+		err.Pos, err.Origin = call, err.Pos
 	}
+	return err
+}
+
+// Apply lets builtins call plang functions.
+func (e *Evaluator) Apply(fn object.Object, args ...object.Object) object.Object {
+	return e.applyFunction(fn, args)
+}
+
+func (e *Evaluator) Output() io.Writer {
+	return e.out
+}
+
+func (e *Evaluator) Rand() *rand.Rand {
+	return e.rand
+}
+
+// syncWriter serializes writes of spawned tasks.
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
 }
+
+func (sw *syncWriter) Write(p []byte) (int, error) {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	return sw.w.Write(p)
+}
+
 func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
 	env := object.NewEnclosedEnvironment(fn.Env)
 	for paramName, param := range fn.Parameters {
@@ -370,7 +929,23 @@ func isTruthy(obj object.Object) bool {
 }
 
 func newError(format string, a ...interface{}) *object.Error {
-	return &object.Error{Message: fmt.Sprintf(format, a...)}
+	return newErrorOfKind(object.RUNTIME_ERROR, format, a...)
+}
+
+func newTypeError(format string, a ...interface{}) *object.Error {
+	return newErrorOfKind(object.TYPE_ERROR, format, a...)
+}
+
+func newNameError(format string, a ...interface{}) *object.Error {
+	return newErrorOfKind(object.NAME_ERROR, format, a...)
+}
+
+func newArgumentError(format string, a ...interface{}) *object.Error {
+	return newErrorOfKind(object.ARGUMENT_ERROR, format, a...)
+}
+
+func newErrorOfKind(kind, format string, a ...interface{}) *object.Error {
+	return &object.Error{Kind: kind, Message: fmt.Sprintf(format, a...)}
 }
 
 func isError(obj object.Object) bool {
@@ -1,11 +1,18 @@
 package evaluator
 
 import (
+	"bytes"
+	"math"
+	"runtime"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/pechorka/plang/lexer"
 	"github.com/pechorka/plang/object"
 	"github.com/pechorka/plang/parser"
+	"github.com/pechorka/plang/token"
 )
 
 func TestEvalIntegerExpression(t *testing.T) {
@@ -17,6 +24,7 @@ func TestEvalIntegerExpression(t *testing.T) {
 		{"10", 10},
 		{"-5", -5},
 		{"-10", -10},
+		{"0xff + 0o7 + 0b1 + 1_000", 1263},
 		{"5 + 5 + 5 + 5 - 10", 10},
 		{"2 * 2 * 2 * 2 * 2", 32},
 		{"-50 + 100 + -50", 0},
@@ -35,6 +43,28 @@ func TestEvalIntegerExpression(t *testing.T) {
 	}
 }
 
+func TestIntegerOverflow(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"9223372036854775807 + 1", "integer overflow: 9223372036854775807 + 1"},
+		{"-9223372036854775807 - 2", "integer overflow: -9223372036854775807 - 2"},
+		{"4611686018427387904 * 2", "integer overflow: 4611686018427387904 * 2"},
+		{"(-9223372036854775807 - 1) / -1", "integer overflow: -9223372036854775808 / -1"},
+		{"-(-9223372036854775807 - 1)", "integer overflow: -(-9223372036854775808)"},
+		{"9223372036854775806 + 1", 9223372036854775807},
+		{"-9223372036854775807 - 1 < 0", true},
+		{"-4611686018427387904 * 2 < 0", true},
+		// the same as math module
+		{"try { 9223372036854775807 + 1 } catch (e) { e[\"kind\"] }", "RuntimeError"},
+	}
+
+	for _, tt := range tests {
+		testValue(t, testEval(tt.input), tt.expected)
+	}
+}
+
 func TestEvalStringLiteral(t *testing.T) {
 	input := `"foobar"`
 	evaluated := testEval(input)
@@ -145,6 +175,48 @@ func TestReturnStatements(t *testing.T) {
 	}
 }
 
+func TestEvalFloatExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"1.5", 1.5},
+		{"-2.25", -2.25},
+		{"1.5 + 1", 2.5},
+		{"1 + 1.5", 2.5},
+		{"0.1 * 3", 0.3},
+		{"7 / 2.0", 3.5},
+		{"1.0 / 0", "division by zero"},
+		{"1 < 1.5", true},
+		{"2.0 == 2", true},
+		{"-0.0 == 0", true},
+		{"2.5 != 2", true},
+		{"9007199254740993 == 9007199254740992.0", false},
+		{"9007199254740992 == 9007199254740992.0", true},
+		{"2.5 != 2.5", false},
+		{"type(1.0)", "FLOAT"},
+	}
+	for _, tt := range tests {
+		testValue(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestFloatInspect(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"2.0", "2.0"},
+		{"1.5", "1.5"},
+		{"2.0 * 500000000000000000", "1e+18"},
+	}
+	for _, tt := range tests {
+		if got := testEval(tt.input).Inspect(); got != tt.expected {
+			t.Errorf("wrong inspect of %q. expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
 func TestErrorHandling(t *testing.T) {
 	tests := []struct {
 		input           string
@@ -195,6 +267,26 @@ func TestErrorHandling(t *testing.T) {
 			`{"name": "Monkey"}[fn(x) { x }];`,
 			"unusable as hash key: FUNCTION",
 		},
+		{
+			"10 / (5 - 5)",
+			"division by zero",
+		},
+		{
+			"fn(x) { x }(1, 2)",
+			"wrong number of arguments. got=2, want=1",
+		},
+		{
+			`throw "bad input"`,
+			"bad input",
+		},
+		{
+			`throw [1, 2]`,
+			"[1, 2]",
+		},
+		{
+			`try { 1 + true } catch (e) { throw e }`,
+			"type mismatch: INTEGER + BOOLEAN",
+		},
 	}
 
 	for _, tt := range tests {
@@ -221,6 +313,7 @@ func TestBuiltinFunctions(t *testing.T) {
 		{`len("")`, 0},
 		{`len("four")`, 4},
 		{`len("hello world")`, 11},
+		{`len("привет")`, 6},
 		{`len(1)`, "argument to `len` not supported, got INTEGER"},
 		{`len("one", "two")`, "wrong number of arguments. got=2, want=1"},
 		{`len([])`, 0},
@@ -386,6 +479,14 @@ func TestArrayIndexExpressions(t *testing.T) {
 		},
 		{
 			"[1, 2, 3][-1]",
+			3,
+		},
+		{
+			"[1, 2, 3][-3]",
+			1,
+		},
+		{
+			"[1, 2, 3][-4]",
 			nil,
 		},
 	}
@@ -401,6 +502,50 @@ func TestArrayIndexExpressions(t *testing.T) {
 	}
 }
 
+func TestStringIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`"привет"[0]`, "п"},
+		{`"привет"[5]`, "т"},
+		{`"привет"[-1]`, "т"},
+		{`"привет"[6]`, nil},
+		{`"привет"[-7]`, nil},
+		{`""[0]`, nil},
+	}
+
+	for _, tt := range tests {
+		testValue(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"[1, 2, 3, 4][1:3]", []int64{2, 3}},
+		{"[1, 2, 3, 4][:2]", []int64{1, 2}},
+		{"[1, 2, 3, 4][2:]", []int64{3, 4}},
+		{"[1, 2, 3, 4][:]", []int64{1, 2, 3, 4}},
+		{"[1, 2, 3, 4][-2:]", []int64{3, 4}},
+		{"[1, 2, 3, 4][:-1]", []int64{1, 2, 3}},
+		{"[1, 2, 3, 4][3:1]", []int64{}},
+		{"[1, 2, 3, 4][-10:10]", []int64{1, 2, 3, 4}},
+		{`"привет"[1:3]`, "ри"},
+		{`"привет"[-3:]`, "вет"},
+		{`"привет"[:0]`, ""},
+		{`let i = 1; "abc"[i:i + 1]`, "b"},
+		{`"abc"["a":]`, "slice bounds must be INTEGER, got STRING"},
+		{`5[1:]`, "slice operator not supported: INTEGER"},
+	}
+
+	for _, tt := range tests {
+		testValue(t, testEval(tt.input), tt.expected)
+	}
+}
+
 func TestHashIndexExpressions(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -435,6 +580,22 @@ func TestHashIndexExpressions(t *testing.T) {
 			`{false: 5}[false]`,
 			5,
 		},
+		{
+			`{1.0: 5}[1]`,
+			5,
+		},
+		{
+			`{0.0: 5}[-0.0]`,
+			5,
+		},
+		{
+			`{2.5: 5}[2.5]`,
+			5,
+		},
+		{
+			`{2: 5}[2.5]`,
+			nil,
+		},
 	}
 
 	for _, tt := range tests {
@@ -483,6 +644,398 @@ func TestHashLiterals(t *testing.T) {
 	}
 }
 
+func TestStructs(t *testing.T) {
+	decl := `struct Point {
+		x, y
+		fn add(other) { Point(self.x + other.x, self.y + other.y) }
+		fn scale(k) { Point(self.x * k, self.y * k) }
+	};
+	`
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let p = Point(1, 2); p.x", 1},
+		{"let p = Point(1, 2); p.y", 2},
+		{"Point(1, 2).add(Point(3, 4)).y", 6},
+		{"let p = Point(1, 2); let f = p.scale; f(10).x", 10},
+		{"let p = Point(1, 2); p.z", "unknown field z on Point"},
+		{"Point(1)", "wrong number of arguments to Point. got=1, want=2"},
+		{"Point(1, 2) + 1", "type mismatch: struct Point + INTEGER"},
+		{"5.x", "member access not supported: INTEGER"},
+		{`let self = "outer"; Point(1, 2).add(Point(1, 1)).x`, 2},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(decl + tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)",
+					evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q",
+					expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestStructTypes(t *testing.T) {
+	input := `struct Point { x, y }; struct Size { x, y };`
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"type(Point(1, 2))", "struct Point"},
+		{"type(Size(1, 2))", "struct Size"},
+		{"type(Point)", "STRUCT"},
+		{"type(1)", "INTEGER"},
+		{`Point(1, "a")`, "Point{x: 1, y: a}"},
+		{"Point", "struct Point { x, y }"},
+		{"Point(1, 2) + Point(1, 2)", "ERROR: unknown operator: struct Point + struct Point"},
+		// names of builtin types are not special
+		{"struct STRING { v }; STRING(1) + STRING(1)", "ERROR: unknown operator: struct STRING + struct STRING"},
+		{"struct ERROR { v }; let e = ERROR(1); [e, 2][1]", "2"},
+		{"struct INTEGER { v }; type(INTEGER(1))", "struct INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(input + tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result. expected=%q, got=%q",
+				tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestErrorSources(t *testing.T) {
+	lib := "let add = fn(a, b) {\n  a + b\n};\nlet apply = fn(f) { f() };"
+	tests := []struct {
+		input          string
+		expectedPos    token.Position
+		expectedOrigin token.Position
+	}{
+		// error in other source is shown at the call
+		{`add(1, true)`, token.Position{Line: 1, Column: 4, Source: "main.pl"}, token.Position{Line: 2, Column: 5, Source: "lib.pl"}},
+		{"let x = 1;\nlet f = fn() { add(x, true) };\nf()", token.Position{Line: 2, Column: 19, Source: "main.pl"}, token.Position{Line: 2, Column: 5, Source: "lib.pl"}},
+		// error, that gets back to its source, is shown where it happened
+		{"apply(fn() {\n  1 + true\n})", token.Position{Line: 2, Column: 5, Source: "main.pl"}, token.Position{}},
+		{`1 + true`, token.Position{Line: 1, Column: 3, Source: "main.pl"}, token.Position{}},
+	}
+
+	for _, tt := range tests {
+		env := object.NewEnvironment()
+		evalNamed(t, "lib.pl", lib, env)
+		obj := evalNamed(t, "main.pl", tt.input, env)
+		errObj, ok := obj.(*object.Error)
+		if !ok {
+			t.Errorf("%q: expected error, got %T (%+v)", tt.input, obj, obj)
+			continue
+		}
+		if errObj.Pos != tt.expectedPos || errObj.Origin != tt.expectedOrigin {
+			t.Errorf("%q: wrong position. expected=%+v from %+v, got=%+v from %+v",
+				tt.input, tt.expectedPos, tt.expectedOrigin, errObj.Pos, errObj.Origin)
+		}
+	}
+}
+
+func evalNamed(t *testing.T, name, input string, env *object.Environment) object.Object {
+	t.Helper()
+	p := parser.New(lexer.NewNamed(name, strings.NewReader(input)))
+	program := p.Parse()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("%s: parser errors: %v", name, p.Errors())
+	}
+	return Eval(program, env)
+}
+
+func TestTryCatch(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`try { 1 } catch (e) { 2 }`, 1},
+		{`try { throw 5; 1 } catch (e) { e + 1 }`, 6},
+		{`try { 1 + true } catch (e) { e["message"] }`, "type mismatch: INTEGER + BOOLEAN"},
+		{`try { 1 + true } catch (e) { e["kind"] }`, "TypeError"},
+		{`try { foo } catch (e) { e["kind"] }`, "NameError"},
+		{`try { len(1, 2) } catch (e) { e["kind"] }`, "ArgumentError"},
+		{`try { 1 / 0 } catch (e) { e["kind"] }`, "RuntimeError"},
+		{"try {\n  1 +\n  true\n} catch (e) { e[\"line\"] }", 2},
+		{"try {\n  1 +\n  true\n} catch (e) { e[\"column\"] }", 5},
+		{`let f = fn() { throw "inner" }; try { f() } catch (e) { e }`, "inner"},
+		{`try { try { throw 1 } catch (e) { throw e + 1 } } catch (e) { e }`, 2},
+		{`try { try { throw 1 } finally { 10 } } catch (e) { e }`, 1},
+		{`let f = fn() { try { return 1; } finally { 2 } }; f()`, 1},
+		{`let f = fn() { try { throw 1 } catch (e) { return e; } finally { 2 } }; f()`, 1},
+		{`let f = fn() { try { return 1; } finally { return 2; } }; f()`, 2},
+		{`try { 1 } finally { throw "from finally" }`, "from finally"},
+		{`let e = 1; try { throw 2 } catch (e) { e }; e`, 1},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if evaluated.Type() == object.ERROR_OBJ {
+				if msg := evaluated.(*object.Error).Message; msg != expected {
+					t.Errorf("wrong error message. expected=%q, got=%q", expected, msg)
+				}
+				continue
+			}
+			testStringObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestForExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let sum = 0; for (x in [1, 2, 3]) { let sum = sum + x; }; sum", 6},
+		{`let s = ""; for (c in "абв") { let s = c + s; }; s`, "вба"},
+		{`let s = 0; for (kv in {"a": 1, "b": 2}) { let s = s + kv[1]; }; s`, 3},
+		{`let keys = ""; for (kv in {"b": 1, "a": 2}) { let keys = keys + kv[0]; }; keys`, "ab"},
+		{"let f = fn() { for (x in [1, 2, 3]) { if (x == 2) { return x; } } }; f()", 2},
+		{"for (x in 5) { x }", "INTEGER is not iterable"},
+		{"for (x in [1]) { x + true }", "type mismatch: INTEGER + BOOLEAN"},
+		// variable of the loop is visible only in its body
+		{"let x = 10; for (x in [1, 2]) {}; x", 10},
+		{"for (x in [1, 2]) {}; x", "identifier not found: x"},
+		{"let x = 10; for (x in [1, 2]) { let x = x * 3 }; x", 10},
+		{"let fs = []; for (x in [1, 2]) { let fs = push(fs, fn() { x }) }; fs[0]() + fs[1]()", 3},
+	}
+
+	for _, tt := range tests {
+		testValue(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestGenerators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let g = fn() { yield 1; yield 2 }; collect(g())", []int64{1, 2}},
+		{"let g = fn(n) { yield n; yield n * 2 }; let s = 0; for (x in g(5)) { let s = s + x; }; s", 15},
+		{"let nat = fn() { let loop = fn(n) { yield n; for (x in loop(n + 1)) { yield x } }; for (x in loop(0)) { yield x } }; take(nat(), 3)", []int64{0, 1, 2}},
+		{"let g = fn() { yield 1; return 5; yield 2 }; collect(g())", []int64{1}},
+		{"let g = fn() { yield 1; yield 1 + true }; collect(g())", "type mismatch: INTEGER + BOOLEAN"},
+		{"let g = fn() { yield 1 }; let it = g(); next(it); next(it)", nil},
+		{"let g = fn() { yield 1 }; type(g())", "ITERATOR"},
+		{"struct R { n; fn each() { yield self.n; yield self.n + 1 } }; collect(R(3).each())", []int64{3, 4}},
+		{"let g = fn() { yield 1 }; try { for (x in g()) { throw x } } catch (e) { e + 1 }", 2},
+	}
+
+	for _, tt := range tests {
+		testValue(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestDroppedGeneratorsStop(t *testing.T) {
+	before := runtime.NumGoroutine()
+	input := `
+	let naturals = fn() {
+		let loop = fn(n) { yield n; for (x in loop(n + 1)) { yield x } };
+		for (x in loop(0)) { yield x }
+	};
+	let g = fn() { try { yield 1; yield 2 } finally { print("stopped") } };
+	for (i in [1, 2, 3, 4, 5, 6, 7, 8, 9, 10]) { take(naturals(), 3); next(g()) }`
+	out := &lockedBuffer{}
+	testValue(t, testEvalWith(New(WithOutput(out)), input), nil)
+
+	expected := strings.Repeat("stopped", 10)
+	deadline := time.Now().Add(5 * time.Second)
+	for (runtime.NumGoroutine() > before || out.String() != expected) && time.Now().Before(deadline) {
+		runtime.GC()
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > before {
+		t.Errorf("goroutines of generators are not stopped: %d before, %d after", before, n)
+	}
+	if out.String() != expected {
+		t.Errorf("finally blocks of generators didn't run. expected=%q, got=%q", expected, out.String())
+	}
+}
+
+// lockedBuffer is written by generators and read by the test at the same time.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestIteratorBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"collect([1, 2])", []int64{1, 2}},
+		{"take([1, 2, 3], 2)", []int64{1, 2}},
+		{"take([1, 2, 3], 5)", []int64{1, 2, 3}},
+		{"take([1], -1)", "second argument to `take` must not be negative, got -1"},
+		{`collect("ab")[1]`, "b"},
+		{"collect(zip([1, 2, 3], [4, 5]))[1]", []int64{2, 5}},
+		{"len(collect(zip([1, 2, 3], [])))", 0},
+		{"collect(enumerate([5, 6]))[1]", []int64{1, 6}},
+		{"let it = iter([1, 2]); next(it); next(it)", 2},
+		{"next([1])", "argument to `next` must be ITERATOR, got ARRAY"},
+		{"collect(1)", "INTEGER is not iterable"},
+		{"zip()", "wrong number of arguments. got=0, want at least 1"},
+	}
+
+	for _, tt := range tests {
+		testValue(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestHigherOrderBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"map([1, 2, 3], fn(x) { x * 2 })", []int64{2, 4, 6}},
+		{"map([], fn(x) { x * 2 })", []int64{}},
+		{"map([1, 2], len)", "argument to `len` not supported, got INTEGER"},
+		{"map([1, 2], fn(x) { x + true })", "type mismatch: INTEGER + BOOLEAN"},
+		{"map(1, fn(x) { x })", "INTEGER is not iterable"},
+		{"map([1], 1)", "not a function: INTEGER"},
+		{`map("ab", fn(c) { c + c })[1]`, "bb"},
+		{"let g = fn() { yield 1; yield 2 }; map(g(), fn(x) { x + 1 })", []int64{2, 3}},
+		{"filter([1, 2, 3, 4], fn(x) { x > 2 })", []int64{3, 4}},
+		{"reduce([1, 2, 3], fn(acc, x) { acc + x })", 6},
+		{"reduce([1, 2, 3], fn(acc, x) { acc + x }, 10)", 16},
+		{"reduce([], fn(acc, x) { acc + x }, 10)", 10},
+		{"reduce([], fn(acc, x) { acc + x })", "`reduce` of empty sequence with no initial value"},
+		{"let ch = channel(3); each([1, 2], fn(x) { send(ch, x) }); recv(ch) + recv(ch)", 3},
+		{"find([1, 2, 3, 4], fn(x) { x > 2 })", 3},
+		{"find([1, 2], fn(x) { x > 2 })", nil},
+		{"any([1, 2, 3], fn(x) { x > 2 })", true},
+		{"any([], fn(x) { true })", false},
+		{"all([1, 2, 3], fn(x) { x > 0 })", true},
+		{"all([1, 2, 3], fn(x) { x > 1 })", false},
+		{"sort_by([3, 1, 2], fn(x) { x })", []int64{1, 2, 3}},
+		{"sort_by([3, 1, 2], fn(x) { -x })", []int64{3, 2, 1}},
+		{`map(sort_by(["bb", "a", "ccc"], fn(s) { s }), len)`, []int64{1, 2, 3}},
+		{`sort_by([1, 2], fn(x) { if (x == 1) { "a" } else { 1 } })`, "`sort_by` can't compare INTEGER with STRING"},
+		{`map(sort_by([[1, "b"], [0, "c"], [1, "a"]], fn(p) { p[0] }), fn(p) { p[1] })`, []interface{}{"c", "b", "a"}},
+		{`sort_by([1], fn(x) { x + true })`, "type mismatch: INTEGER + BOOLEAN"},
+		{`sort_by(5, fn(x) { x })`, "INTEGER is not iterable"},
+		{`let g = group_by([1, 2, 3, 4, 5], fn(x) { x > 2 }); g[true]`, []int64{3, 4, 5}},
+		{`let g = group_by([1, 2, 3, 4, 5], fn(x) { x > 2 }); g[false]`, []int64{1, 2}},
+		{`group_by([1], fn(x) { [x] })`, "unusable as hash key: ARRAY"},
+	}
+
+	for _, tt := range tests {
+		testValue(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestSortByIsStable(t *testing.T) {
+	input := `
+	struct P { name, age }
+	let people = [P("a", 30), P("b", 20), P("c", 30), P("d", 20)];
+	map(sort_by(people, fn(p) { p.age }), fn(p) { p.name })`
+	evaluated := testEval(input)
+	if evaluated.Inspect() != "[b, d, a, c]" {
+		t.Errorf("wrong order. got=%s", evaluated.Inspect())
+	}
+}
+
+func TestSpawn(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let add = fn(a, b) { a + b }; wait(spawn add(1, 2))", 3},
+		{"let x = 5; wait(spawn fn() { x * 2 })", 10},
+		{"let sq = fn(x) { x * x }; wait([spawn sq(2), spawn sq(3)])", []int64{4, 9}},
+		{"let f = fn() { 1 + true }; wait(spawn f())", "type mismatch: INTEGER + BOOLEAN"},
+		{"let t = spawn fn() { 1 }; type(t)", "TASK"},
+		{"spawn 1", "not a function: INTEGER"},
+		{"wait(1)", "argument to `wait` must be TASK or ARRAY, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		testValue(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestChannels(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let ch = channel(1); send(ch, 5); recv(ch)", 5},
+		{"let ch = channel(); spawn fn() { send(ch, 7) }; recv(ch)", 7},
+		{"let ch = channel(); close(ch); recv(ch)", nil},
+		{"let ch = channel(1); close(ch); send(ch, 1)", "send on closed channel"},
+		{"let ch = channel(); close(ch); close(ch)", "close of closed channel"},
+		{"channel(-1)", "channel capacity must not be negative, got -1"},
+		{`
+		let ch = channel();
+		let produce = fn(n) {
+			for (x in [1, 2, 3]) { send(ch, x * n); }
+			close(ch);
+		};
+		spawn produce(10);
+		collect(ch)`, []int64{10, 20, 30}},
+		{`
+		let results = channel(3);
+		let work = fn(x) { send(results, x * x) };
+		wait([spawn work(1), spawn work(2), spawn work(3)]);
+		close(results);
+		let sum = 0;
+		for (r in results) { let sum = sum + r; };
+		sum`, 14},
+		{"let a = channel(); let b = channel(1); send(b, 3); select([a, b])", []int64{1, 3}},
+		{"let a = channel(1); let b = channel(); select([b, [a, 5]])[0]", 1},
+		{"let a = channel(1); select([[a, 5]]); recv(a)", 5},
+		{"let a = channel(); close(a); select([[a, 5]])", "send on closed channel"},
+		{"select([1])", "case of `select` must be CHANNEL or ARRAY, got INTEGER at index 0"},
+		{"select([[]])", "send case of `select` must be [CHANNEL, value], got [] at index 0"},
+	}
+
+	for _, tt := range tests {
+		testValue(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestErrorPosition(t *testing.T) {
+	input := `let f = fn(x) {
+  x + true
+};
+f(1)`
+	evaluated := testEval(input)
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+	if errObj.Pos.String() != "2:5" {
+		t.Errorf("wrong error position. expected=%q, got=%q", "2:5", errObj.Pos)
+	}
+}
+
 func testEval(input string) object.Object {
 	l := lexer.NewFromString(input)
 	p := parser.New(l)
@@ -491,6 +1044,52 @@ func testEval(input string) object.Object {
 	return Eval(program, env)
 }
 
+// testValue checks obj against expected value: int, string, []int64 or nil.
+// String is compared with error message, if obj is an error.
+func testValue(t *testing.T, obj object.Object, expected interface{}) bool {
+	t.Helper()
+	switch expected := expected.(type) {
+	case int:
+		return testIntegerObject(t, obj, int64(expected))
+	case float64:
+		return testFloatObject(t, obj, expected)
+	case []int64:
+		return testIntegerArrayObject(t, obj, expected)
+	case []interface{}:
+		arr, ok := obj.(*object.Array)
+		if !ok {
+			t.Errorf("object is not Array. got=%T (%+v)", obj, obj)
+			return false
+		}
+		if len(arr.Elements) != len(expected) {
+			t.Errorf("wrong number of elements. expected=%d, got=%d", len(expected), len(arr.Elements))
+			return false
+		}
+		for i, el := range expected {
+			if !testValue(t, arr.Elements[i], el) {
+				return false
+			}
+		}
+		return true
+	case string:
+		if errObj, ok := obj.(*object.Error); ok {
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q",
+					expected, errObj.Message)
+				return false
+			}
+			return true
+		}
+		return testStringObject(t, obj, expected)
+	case bool:
+		return testBooleanObject(t, obj, expected)
+	case nil:
+		return testNullObject(t, obj)
+	}
+	t.Errorf("type of expected value not handled. got=%T", expected)
+	return false
+}
+
 func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
 	result, ok := obj.(*object.Integer)
 	if !ok {
@@ -505,6 +1104,20 @@ func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
 	return true
 }
 
+func testFloatObject(t *testing.T, obj object.Object, expected float64) bool {
+	result, ok := obj.(*object.Float)
+	if !ok {
+		t.Errorf("object is not Float. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if math.Abs(result.Value-expected) > 1e-9 {
+		t.Errorf("object has wrong value. got=%g, want=%g",
+			result.Value, expected)
+		return false
+	}
+	return true
+}
+
 func testIntegerArrayObject(t *testing.T, obj object.Object, expected []int64) bool {
 	if expected == nil {
 		if obj != NULL {
@@ -0,0 +1,315 @@
+package evaluator
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pechorka/plang/object"
+)
+
+func init() {
+	registerBuiltins(disabledBuiltins("file system", (&sandboxFS{}).builtins()))
+}
+
+// WithFS grants scripts access to files under root directory.
+// Relative paths are resolved against root, and no path,
+// including one going through a symlink, may point outside of it.
+func WithFS(root string) Option {
+	return func(e *Evaluator) {
+		fs := newSandboxFS(root)
+		for name, b := range nameBuiltins(fs.builtins()) {
+			e.builtins[name] = b
+		}
+	}
+}
+
+type sandboxFS struct {
+	root string
+}
+
+func newSandboxFS(root string) *sandboxFS {
+	if abs, err := filepath.Abs(root); err == nil {
+		root = abs
+	}
+	if resolved, err := filepath.EvalSymlinks(root); err == nil {
+		root = resolved
+	}
+	return &sandboxFS{root: filepath.Clean(root)}
+}
+
+func (fs *sandboxFS) builtins() map[string]*object.Builtin {
+	return map[string]*object.Builtin{
+		"read_file": {
+			Params: []string{"path"},
+			Doc:    "Returns content of the file.",
+			Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+				f, err := fs.open("read_file", args[0], os.O_RDONLY, 0)
+				if err != nil {
+					return err
+				}
+				content, rerr := io.ReadAll(f)
+				f.Close()
+				if rerr != nil {
+					return newIOError("read_file", args[0], rerr)
+				}
+				return &object.String{Value: string(content)}
+			},
+		},
+		"write_file": {
+			Params: []string{"path", "content"},
+			Doc:    "Writes the content to the file, replacing existing one.",
+			Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+				return fs.write("write_file", os.O_TRUNC, args)
+			},
+		},
+		"append_file": {
+			Params: []string{"path", "content"},
+			Doc:    "Appends the content to the file.",
+			Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+				return fs.write("append_file", os.O_APPEND, args)
+			},
+		},
+		"read_lines": {
+			Params: []string{"path"},
+			Doc:    "Returns iterator over lines of the file.",
+			Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+				f, err := fs.open("read_lines", args[0], os.O_RDONLY, 0)
+				if err != nil {
+					return err
+				}
+				return readLines(f, args[0])
+			},
+		},
+		"list_dir": {
+			Params: []string{"path"},
+			Doc:    "Returns sorted array of names of entries of the directory.",
+			Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+				f, err := fs.open("list_dir", args[0], os.O_RDONLY, 0)
+				if err != nil {
+					return err
+				}
+				entries, rerr := f.ReadDir(-1)
+				f.Close()
+				if rerr != nil {
+					return newIOError("list_dir", args[0], rerr)
+				}
+				names := make([]string, len(entries))
+				for i, entry := range entries {
+					names[i] = entry.Name()
+				}
+				sort.Strings(names)
+				return stringsToArray(names)
+			},
+		},
+		"exists": {
+			Params: []string{"path"},
+			Doc:    "Reports whether the file or directory exists.",
+			Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+				path, err := fs.pathArg("exists", args[0])
+				if err != nil {
+					return err
+				}
+				_, serr := os.Stat(path)
+				if os.IsNotExist(serr) {
+					return FALSE
+				}
+				if serr != nil {
+					return newIOError("exists", args[0], serr)
+				}
+				return TRUE
+			},
+		},
+		"remove": {
+			Params: []string{"path"},
+			Doc:    "Removes the file or empty directory.",
+			Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+				path, err := fs.pathArg("remove", args[0])
+				if err != nil {
+					return err
+				}
+				if path == fs.root {
+					return newErrorOfKind(object.PERMISSION_ERROR, "remove: can't remove root directory")
+				}
+				// not recursive, non empty directory is an error
+				if rerr := os.Remove(path); rerr != nil {
+					return newIOError("remove", args[0], rerr)
+				}
+				return NULL
+			},
+		},
+		"mkdir": {
+			Params: []string{"path"},
+			Doc:    "Creates the directory with its parents.",
+			Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+				path, err := fs.pathArg("mkdir", args[0])
+				if err != nil {
+					return err
+				}
+				// creates parents and is no-op for existing directory
+				if merr := os.MkdirAll(path, 0o755); merr != nil {
+					return newIOError("mkdir", args[0], merr)
+				}
+				return NULL
+			},
+		},
+	}
+}
+
+// write writes content to the file, creating it if needed.
+// flag is either os.O_TRUNC or os.O_APPEND.
+func (fs *sandboxFS) write(name string, flag int, args []object.Object) object.Object {
+	content, ok := args[1].(*object.String)
+	if !ok {
+		return newTypeError("second argument to `%s` must be STRING, got %s", name, args[1].Type())
+	}
+	f, err := fs.open(name, args[0], os.O_WRONLY|os.O_CREATE|flag, 0o644)
+	if err != nil {
+		return err
+	}
+	_, werr := f.WriteString(content.Value)
+	if cerr := f.Close(); werr == nil {
+		werr = cerr
+	}
+	if werr != nil {
+		return newIOError(name, args[0], werr)
+	}
+	return NULL
+}
+
+// pathArg resolves path argument against the root
+// and checks, that the result does not escape the root.
+func (fs *sandboxFS) pathArg(name string, arg object.Object) (string, *object.Error) {
+	str, ok := arg.(*object.String)
+	if !ok {
+		return "", newTypeError("path argument to `%s` must be STRING, got %s", name, arg.Type())
+	}
+	path := str.Value
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(fs.root, path)
+	}
+	path = filepath.Clean(path)
+	if !fs.contains(path) || !fs.contains(resolveSymlinks(path)) {
+		return "", outsideError(name, str.Value)
+	}
+	return path, nil
+}
+
+// open opens the file at path argument. Symlinks are resolved before the check
+// and the resolved path is opened without following links, so link, that is
+// created after the check, is not followed. The opened file is checked again,
+// because directories of the path can be replaced with links too.
+func (fs *sandboxFS) open(name string, arg object.Object, flag int, perm os.FileMode) (*os.File, *object.Error) {
+	path, err := fs.pathArg(name, arg)
+	if err != nil {
+		return nil, err
+	}
+	f, oerr := os.OpenFile(resolveSymlinks(path), flag|noFollow, perm)
+	if oerr != nil {
+		return nil, newIOError(name, arg, oerr)
+	}
+	if opened, ok := openedPath(f); ok && !fs.contains(opened) {
+		f.Close()
+		return nil, outsideError(name, arg.(*object.String).Value)
+	}
+	return f, nil
+}
+
+func outsideError(name, path string) *object.Error {
+	return newErrorOfKind(object.PERMISSION_ERROR, "%s: path %q is outside of allowed directory", name, path)
+}
+
+func (fs *sandboxFS) contains(path string) bool {
+	rel, err := filepath.Rel(fs.root, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// resolveSymlinks resolves symlinks of the path, including dangling ones,
+// which are followed when the file is created. Rest of the path after
+// the longest existing prefix does not exist yet, so it can't contain symlinks.
+// Empty path is returned for loops of links.
+func resolveSymlinks(path string) string {
+	for hops := 0; hops < maxSymlinks; hops++ {
+		prefix, rest := path, ""
+		for {
+			if resolved, err := filepath.EvalSymlinks(prefix); err == nil {
+				return filepath.Join(resolved, rest)
+			}
+			if info, err := os.Lstat(prefix); err == nil && info.Mode()&os.ModeSymlink != 0 {
+				break // dangling link
+			}
+			parent := filepath.Dir(prefix)
+			if parent == prefix {
+				return filepath.Join(prefix, rest)
+			}
+			rest = filepath.Join(filepath.Base(prefix), rest)
+			prefix = parent
+		}
+		target, err := os.Readlink(prefix)
+		if err != nil {
+			return ""
+		}
+		if !filepath.IsAbs(target) {
+			dir := filepath.Dir(prefix)
+			if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+				dir = resolved
+			}
+			target = filepath.Join(dir, target)
+		}
+		path = filepath.Join(target, rest)
+	}
+	return ""
+}
+
+const maxSymlinks = 40 // the limit of links in path on linux
+
+// openedPath returns the path of the opened file, as the system sees it.
+func openedPath(f *os.File) (string, bool) {
+	path, err := os.Readlink("/proc/self/fd/" + strconv.Itoa(int(f.Fd())))
+	if err != nil { // no procfs
+		return "", false
+	}
+	return path, true
+}
+
+// readLines returns iterator over lines of the file without line terminators.
+// File is closed, when the iterator is exhausted.
+func readLines(f *os.File, path object.Object) *object.Iterator {
+	r := bufio.NewReader(f)
+	done := false
+	return &object.Iterator{Next: func() (object.Object, bool) {
+		if done {
+			return nil, false
+		}
+		line, err := r.ReadString('\n')
+		if err != nil {
+			done = true
+			f.Close()
+			if err != io.EOF {
+				return newIOError("read_lines", path, err), true
+			}
+			if line == "" {
+				return nil, false
+			}
+		}
+		line = strings.TrimSuffix(line, "\n")
+		line = strings.TrimSuffix(line, "\r")
+		return &object.String{Value: line}, true
+	}}
+}
+
+// newIOError reports err with the path, as it was given by the script,
+// so errors don't reveal location of the root directory.
+func newIOError(name string, path object.Object, err error) *object.Error {
+	if pathErr, ok := err.(*os.PathError); ok {
+		err = pathErr.Err
+	}
+	return newErrorOfKind(object.IO_ERROR, "%s: %q: %v", name, path.Inspect(), err)
+}
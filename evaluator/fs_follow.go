@@ -0,0 +1,7 @@
+//go:build windows || plan9
+// +build windows plan9
+
+package evaluator
+
+// noFollow is not supported, paths are only checked before open.
+const noFollow = 0
@@ -0,0 +1,9 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package evaluator
+
+import "syscall"
+
+// noFollow makes open fail, if the last element of the path is symlink.
+const noFollow = syscall.O_NOFOLLOW
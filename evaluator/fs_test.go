@@ -0,0 +1,139 @@
+package evaluator
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pechorka/plang/lexer"
+	"github.com/pechorka/plang/object"
+	"github.com/pechorka/plang/parser"
+)
+
+func TestFSBuiltins(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "lines.txt"), []byte("a\r\nб\n\nc"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	e := New(WithFS(dir))
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`write_file("out.txt", "при")`, nil},
+		{`read_file("out.txt")`, "при"},
+		{`append_file("out.txt", "вет")`, nil},
+		{`read_file("out.txt")`, "привет"},
+		{`write_file("out.txt", "x")`, nil},
+		{`read_file("out.txt")`, "x"},
+		{`collect(read_lines("lines.txt"))`, nil},
+		{`len(collect(read_lines("lines.txt")))`, 4},
+		{`collect(read_lines("lines.txt"))[1]`, "б"},
+		{`collect(read_lines("lines.txt"))[3]`, "c"},
+		{`exists("out.txt")`, true},
+		{`exists("missing.txt")`, false},
+		{`list_dir(".")`, nil},
+		{`len(list_dir("."))`, 3},
+		{`list_dir(".")[0]`, "lines.txt"},
+		{`mkdir("a/b")`, nil},
+		{`exists("sub/../a/b")`, true},
+		{`remove("a/b")`, nil},
+		{`exists("a/b")`, false},
+		{`remove("missing.txt")`, `remove: "missing.txt": no such file or directory`},
+		{`read_file("missing.txt")`, `read_file: "missing.txt": no such file or directory`},
+		{`remove(".")`, "remove: can't remove root directory"},
+		{`read_file("../secret")`, `read_file: path "../secret" is outside of allowed directory`},
+		{`read_file("/etc/passwd")`, `read_file: path "/etc/passwd" is outside of allowed directory`},
+		{`read_file(1)`, "path argument to `read_file` must be STRING, got INTEGER"},
+		{`write_file("out.txt", 1)`, "second argument to `write_file` must be STRING, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		obj := testEvalWith(e, tt.input)
+		if tt.expected == nil {
+			if isError(obj) {
+				t.Errorf("unexpected error for %q: %s", tt.input, obj.Inspect())
+			}
+			continue
+		}
+		testValue(t, obj, tt.expected)
+	}
+}
+
+func TestFSSymlinkEscape(t *testing.T) {
+	dir := t.TempDir()
+	outside := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(dir, "link")); err != nil {
+		t.Skipf("symlinks are not supported: %v", err)
+	}
+	e := New(WithFS(dir))
+
+	testValue(t, testEvalWith(e, `write_file("link/x.txt", "x")`),
+		`write_file: path "link/x.txt" is outside of allowed directory`)
+	if _, err := os.Stat(filepath.Join(outside, "x.txt")); !os.IsNotExist(err) {
+		t.Errorf("file was created outside of the root: %v", err)
+	}
+}
+
+func TestFSDanglingSymlink(t *testing.T) {
+	dir := t.TempDir()
+	outside := filepath.Join(t.TempDir(), "target")
+	if err := os.Symlink(outside, filepath.Join(dir, "out")); err != nil {
+		t.Skipf("symlinks are not supported: %v", err)
+	}
+	if err := os.Symlink(filepath.Join(dir, "new.txt"), filepath.Join(dir, "in")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("loop", filepath.Join(dir, "loop")); err != nil {
+		t.Fatal(err)
+	}
+	e := New(WithFS(dir))
+
+	testValue(t, testEvalWith(e, `write_file("out", "x")`), `write_file: path "out" is outside of allowed directory`)
+	testValue(t, testEvalWith(e, `append_file("out", "x")`), `append_file: path "out" is outside of allowed directory`)
+	testValue(t, testEvalWith(e, `mkdir("out/sub")`), `mkdir: path "out/sub" is outside of allowed directory`)
+	testValue(t, testEvalWith(e, `write_file("loop", "x")`), `write_file: path "loop" is outside of allowed directory`)
+	if _, err := os.Lstat(outside); !os.IsNotExist(err) {
+		t.Errorf("file was created outside of the root: %v", err)
+	}
+
+	// dangling link to the root can be followed
+	testValue(t, testEvalWith(e, `write_file("in", "x")`), nil)
+	testValue(t, testEvalWith(e, `read_file("new.txt")`), "x")
+}
+
+func TestFSDisabledByDefault(t *testing.T) {
+	for name, b := range nameBuiltins((&sandboxFS{}).builtins()) {
+		obj := testEval(testCall(b))
+		errObj, ok := obj.(*object.Error)
+		if !ok {
+			t.Fatalf("%s: expected error, got %T (%+v)", name, obj, obj)
+		}
+		if errObj.Kind != object.PERMISSION_ERROR {
+			t.Errorf("%s: wrong error kind. expected=%q, got=%q", name, object.PERMISSION_ERROR, errObj.Kind)
+		}
+		expected := name + ": file system access is not enabled"
+		if errObj.Message != expected {
+			t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+		}
+	}
+}
+
+// testCall returns call of the builtin with minimal number of string arguments.
+func testCall(b *object.Builtin) string {
+	min, _ := b.Arity()
+	return b.Name + "(" + strings.TrimSuffix(strings.Repeat(`"x", `, min), ", ") + ")"
+}
+
+func testEvalWith(e *Evaluator, input string) object.Object {
+	l := lexer.NewFromString(input)
+	p := parser.New(l)
+	program := p.Parse()
+	env := object.NewEnvironment()
+	return e.Eval(program, env)
+}
@@ -0,0 +1,110 @@
+package evaluator
+
+import (
+	"runtime"
+
+	"github.com/pechorka/plang/object"
+)
+
+// yielderName is the name under which generator is stored in the environment
+// of generator function call. yield is a keyword, so it can't clash with user variables.
+const yielderName = "yield"
+
+// generatorClosed is the value of the error, that is returned by yield of generator,
+// which was dropped by its consumer. The error can't be caught,
+// so the body of the generator unwinds, running finally blocks.
+var generatorClosed = &object.String{Value: "generator is closed"}
+
+func newGeneratorClosedError() *object.Error {
+	return &object.Error{Kind: object.RUNTIME_ERROR, Message: generatorClosed.Value, Value: generatorClosed}
+}
+
+func isGeneratorClosed(err *object.Error) bool {
+	return err.Value == generatorClosed
+}
+
+// generator runs body of generator function on a separate goroutine.
+// Goroutine is blocked on every yield, until consumer asks for the next value,
+// so body of the generator and consumer never run at the same time.
+//
+// Generator, that is not consumed until the end, is closed, when its iterator
+// is garbage collected, and its goroutine stops.
+type generator struct {
+	values chan object.Object
+	resume chan struct{}
+	done   chan struct{} // closed, when the iterator is dropped
+}
+
+func (g *generator) Type() object.Type {
+	return "GENERATOR"
+}
+
+func (g *generator) Inspect() string {
+	return "generator"
+}
+
+func (e *Evaluator) newGenerator(fn *object.Function, args []object.Object) *object.Iterator {
+	env := extendFunctionEnv(fn, args)
+	g := &generator{
+		values: make(chan object.Object),
+		resume: make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	env.Set(yielderName, g)
+
+	started, done := false, false
+	next := func() (object.Object, bool) {
+		if done {
+			return nil, false
+		}
+		if started {
+			g.resume <- struct{}{}
+		} else {
+			started = true
+			go g.run(e, fn, env)
+		}
+		val, ok := <-g.values
+		if !ok || isError(val) {
+			done = true
+		}
+		return val, ok
+	}
+
+	it := &object.Iterator{Next: next}
+	// goroutine of the generator doesn't reference the iterator,
+	// so the iterator is collected, when the consumer drops it
+	runtime.SetFinalizer(it, func(*object.Iterator) { close(g.done) })
+	return it
+}
+
+func (g *generator) run(e *Evaluator, fn *object.Function, env *object.Environment) {
+	defer close(g.values)
+	result := e.Eval(fn.Body, env)
+	if err, ok := result.(*object.Error); ok && !isGeneratorClosed(err) {
+		select {
+		case g.values <- result:
+		case <-g.done:
+		}
+	}
+}
+
+// yield passes val to the consumer of the generator
+// and waits until the consumer asks for the next value.
+func yield(val object.Object, env *object.Environment) object.Object {
+	obj, ok := env.Get(yielderName)
+	if !ok {
+		return newError("yield outside of generator")
+	}
+	g := obj.(*generator)
+	select {
+	case g.values <- val:
+	case <-g.done:
+		return newGeneratorClosedError()
+	}
+	select {
+	case <-g.resume:
+	case <-g.done:
+		return newGeneratorClosedError()
+	}
+	return NULL
+}
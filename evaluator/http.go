@@ -0,0 +1,288 @@
+package evaluator
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pechorka/plang/object"
+)
+
+func init() {
+	registerModule(disabledModule("network", httpModule))
+}
+
+const defaultHTTPTimeout = 30 * time.Second
+
+// WithNetwork grants scripts access to the http module.
+func WithNetwork() Option {
+	return func(e *Evaluator) {
+		e.modules[httpModule.Name] = httpModule
+	}
+}
+
+// Requests and responses are hashes:
+//
+//	request:  {"method", "url", "headers", "body", "timeout"}
+//	response: {"status", "headers", "body"}
+//
+// Headers are hashes of strings, multiple values of a header are joined with ", ".
+// Response with error status is not an error, only failure to get a response is.
+var httpModule = newModule("http", map[string]object.Object{
+	"get": &object.Builtin{
+		Params: []string{"url", "options?"},
+		Doc:    "Sends GET request and returns {\"status\", \"headers\", \"body\"}. Options are {\"headers\", \"timeout\"}.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			req, err := httpRequestArgs("get", "GET", args[0], nil, args[1:])
+			if err != nil {
+				return err
+			}
+			return doHTTPRequest("get", req)
+		},
+	},
+	"post": &object.Builtin{
+		Params: []string{"url", "body", "options?"},
+		Doc:    "Sends POST request with the body, see http.get.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			req, err := httpRequestArgs("post", "POST", args[0], args[1], args[2:])
+			if err != nil {
+				return err
+			}
+			return doHTTPRequest("post", req)
+		},
+	},
+	"request": &object.Builtin{
+		Params: []string{"options"},
+		Doc:    "Sends request described by {\"method\", \"url\", \"headers\", \"body\", \"timeout\"}, see http.get.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			opts, ok := args[0].(*object.Hash)
+			if !ok {
+				return newTypeError("argument to `http.request` must be HASH, got %s", args[0].Type())
+			}
+			url, ok := hashField(opts, "url")
+			if !ok {
+				return newArgumentError("http.request: url is required")
+			}
+			method := "GET"
+			if m, ok := hashField(opts, "method"); ok {
+				str, ok := m.(*object.String)
+				if !ok {
+					return newTypeError("http.request: method must be STRING, got %s", m.Type())
+				}
+				method = strings.ToUpper(str.Value)
+			}
+			body, _ := hashField(opts, "body")
+			req, err := httpRequestArgs("request", method, url, body, args)
+			if err != nil {
+				return err
+			}
+			return doHTTPRequest("request", req)
+		},
+	},
+	"serve": &object.Builtin{
+		Params: []string{"addr", "handler"},
+		Doc:    "Serves HTTP on the address, calling handler with request hash. Handler returns {\"status\", \"headers\", \"body\"} or body string.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			addr, ok := args[0].(*object.String)
+			if !ok {
+				return newTypeError("first argument to `http.serve` must be STRING, got %s", args[0].Type())
+			}
+			switch args[1].(type) {
+			case *object.Function, *object.Builtin:
+			default:
+				return newTypeError("second argument to `http.serve` must be FUNCTION, got %s", args[1].Type())
+			}
+			// blocks until the server fails
+			err := http.ListenAndServe(addr.Value, httpHandler(in, args[1]))
+			return newErrorOfKind(object.IO_ERROR, "http.serve: %s", err)
+		},
+	},
+})
+
+type httpRequest struct {
+	method  string
+	url     string
+	body    string
+	headers map[string]string
+	timeout time.Duration
+}
+
+// httpRequestArgs builds request from url, optional body and optional hash of options.
+func httpRequestArgs(name, method string, url, body object.Object, opts []object.Object) (*httpRequest, *object.Error) {
+	urlStr, ok := url.(*object.String)
+	if !ok {
+		return nil, newTypeError("url argument to `http.%s` must be STRING, got %s", name, url.Type())
+	}
+	req := &httpRequest{method: method, url: urlStr.Value, timeout: defaultHTTPTimeout}
+	if body != nil {
+		bodyStr, ok := body.(*object.String)
+		if !ok {
+			return nil, newTypeError("body argument to `http.%s` must be STRING, got %s", name, body.Type())
+		}
+		req.body = bodyStr.Value
+	}
+	if len(opts) == 0 {
+		return req, nil
+	}
+	hash, ok := opts[0].(*object.Hash)
+	if !ok {
+		return nil, newTypeError("options argument to `http.%s` must be HASH, got %s", name, opts[0].Type())
+	}
+	if headers, ok := hashField(hash, "headers"); ok {
+		h, err := stringHash(headers)
+		if err != nil {
+			return nil, newTypeError("http.%s: headers %s", name, err)
+		}
+		req.headers = h
+	}
+	if timeout, ok := hashField(hash, "timeout"); ok {
+		d, ok := timeout.(*object.Duration)
+		if !ok {
+			return nil, newTypeError("http.%s: timeout must be DURATION, got %s", name, timeout.Type())
+		}
+		req.timeout = d.Value
+	}
+	return req, nil
+}
+
+func doHTTPRequest(name string, req *httpRequest) object.Object {
+	ctx, cancel := context.WithTimeout(context.Background(), req.timeout)
+	defer cancel()
+
+	var body io.Reader
+	if req.body != "" {
+		body = strings.NewReader(req.body)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, req.url, body)
+	if err != nil {
+		return newArgumentError("http.%s: %s", name, err)
+	}
+	for k, v := range req.headers {
+		httpReq.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		return newErrorOfKind(object.IO_ERROR, "http.%s: %s", name, err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return newErrorOfKind(object.IO_ERROR, "http.%s: reading body: %s", name, err)
+	}
+	return newHash(map[string]object.Object{
+		"status":  &object.Integer{Value: int64(resp.StatusCode)},
+		"headers": headersToHash(resp.Header),
+		"body":    &object.String{Value: string(respBody)},
+	})
+}
+
+// httpHandler calls plang handler with request hash
+// {"method", "path", "query", "headers", "body"} and writes response from the returned hash,
+// where all fields are optional, or string, which is used as a body.
+// Errors of the handler are reported as 500 without details.
+func httpHandler(in object.Interpreter, handler object.Object) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "can't read request body", http.StatusBadRequest)
+			return
+		}
+		query := make(map[string]object.Object)
+		for k, v := range r.URL.Query() {
+			query[k] = &object.String{Value: v[0]}
+		}
+		req := newHash(map[string]object.Object{
+			"method":  &object.String{Value: r.Method},
+			"path":    &object.String{Value: r.URL.Path},
+			"query":   newHash(query),
+			"headers": headersToHash(r.Header),
+			"body":    &object.String{Value: string(body)},
+		})
+
+		resp := in.Apply(handler, req)
+		if err := writeHTTPResponse(w, resp); err != nil {
+			fmt.Fprintf(in.Output(), "http.serve: %s %s: %s\n", r.Method, r.URL.Path, err.Message)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+	})
+}
+
+func writeHTTPResponse(w http.ResponseWriter, resp object.Object) *object.Error {
+	switch resp := resp.(type) {
+	case *object.Error:
+		return resp
+	case *object.String:
+		io.WriteString(w, resp.Value)
+		return nil
+	case *object.Hash:
+		status := http.StatusOK
+		if s, ok := hashField(resp, "status"); ok {
+			i, ok := s.(*object.Integer)
+			if !ok || i.Value < 100 || i.Value > 999 {
+				return newTypeError("response status must be INTEGER between 100 and 999, got %s", s.Inspect())
+			}
+			status = int(i.Value)
+		}
+		body := ""
+		if b, ok := hashField(resp, "body"); ok {
+			str, ok := b.(*object.String)
+			if !ok {
+				return newTypeError("response body must be STRING, got %s", b.Type())
+			}
+			body = str.Value
+		}
+		if headers, ok := hashField(resp, "headers"); ok {
+			h, err := stringHash(headers)
+			if err != nil {
+				return newTypeError("response headers %s", err)
+			}
+			for k, v := range h {
+				w.Header().Set(k, v)
+			}
+		}
+		w.WriteHeader(status)
+		io.WriteString(w, body)
+		return nil
+	}
+	return newTypeError("handler must return HASH or STRING, got %s", resp.Type())
+}
+
+func headersToHash(header http.Header) *object.Hash {
+	fields := make(map[string]object.Object, len(header))
+	for k, v := range header {
+		fields[k] = &object.String{Value: strings.Join(v, ", ")}
+	}
+	return newHash(fields)
+}
+
+// stringHash converts hash with string keys and values to a map.
+// Error describes the offending value and is meant to be prefixed with its name.
+func stringHash(obj object.Object) (map[string]string, error) {
+	hash, ok := obj.(*object.Hash)
+	if !ok {
+		return nil, fmt.Errorf("must be HASH, got %s", obj.Type())
+	}
+	m := make(map[string]string, len(hash.Pairs))
+	for _, pair := range hash.SortedPairs() {
+		k, kok := pair.Key.(*object.String)
+		v, vok := pair.Value.(*object.String)
+		if !kok || !vok {
+			return nil, fmt.Errorf("must map STRING to STRING, got %s: %s", pair.Key.Type(), pair.Value.Type())
+		}
+		m[k.Value] = v.Value
+	}
+	return m, nil
+}
+
+// newHash creates hash with string keys.
+func newHash(fields map[string]object.Object) *object.Hash {
+	pairs := make(map[object.HashKey]object.HashPair, len(fields))
+	for k, v := range fields {
+		key := &object.String{Value: k}
+		pairs[key.HashKey()] = object.HashPair{Key: key, Value: v}
+	}
+	return &object.Hash{Pairs: pairs}
+}
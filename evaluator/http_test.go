@@ -0,0 +1,137 @@
+package evaluator
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pechorka/plang/object"
+)
+
+func TestHTTPClient(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(200 * time.Millisecond)
+		}
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Method", r.Method)
+		w.Header().Add("X-Multi", "a")
+		w.Header().Add("X-Multi", "b")
+		w.WriteHeader(http.StatusTeapot)
+		fmt.Fprintf(w, "%s|%s|%s", r.URL.Path, r.Header.Get("X-Token"), body)
+	}))
+	defer srv.Close()
+	e := New(WithNetwork())
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`http.get("URL/a")["status"]`, 418},
+		{`http.get("URL/a")["body"]`, "/a||"},
+		{`http.get("URL/a")["headers"]["X-Method"]`, "GET"},
+		{`http.get("URL/a")["headers"]["X-Multi"]`, "a, b"},
+		{`http.get("URL/a", {"headers": {"X-Token": "t"}})["body"]`, "/a|t|"},
+		{`http.post("URL/p", "data")["body"]`, "/p||data"},
+		{`http.post("URL/p", "data")["headers"]["X-Method"]`, "POST"},
+		{`http.request({"method": "put", "url": "URL/r", "body": "b", "headers": {"X-Token": "t"}})["body"]`, "/r|t|b"},
+		{`http.request({"method": "delete", "url": "URL/r"})["headers"]["X-Method"]`, "DELETE"},
+		{`http.request({"method": "get"})`, "http.request: url is required"},
+		{`http.get("URL/slow", {"timeout": duration("20ms")})["status"]`, "http.get: Get \"URL/slow\": context deadline exceeded"},
+		{`http.get("URL/a", {"timeout": 5})`, "http.get: timeout must be DURATION, got INTEGER"},
+		{`http.get("URL/a", {"headers": {"X-Token": 1}})`, "http.get: headers must map STRING to STRING, got STRING: INTEGER"},
+		{`http.get(1)`, "url argument to `http.get` must be STRING, got INTEGER"},
+		{`http.post("URL/p", 1)`, "body argument to `http.post` must be STRING, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		input := strings.ReplaceAll(tt.input, "URL", srv.URL)
+		expected := tt.expected
+		if str, ok := expected.(string); ok {
+			expected = strings.ReplaceAll(str, "URL", srv.URL)
+		}
+		testValue(t, testEvalWith(e, input), expected)
+	}
+}
+
+func TestHTTPServe(t *testing.T) {
+	var out strings.Builder
+	e := New(WithNetwork(), WithOutput(&out))
+	handler := testEvalWith(e, `fn(req) {
+	if (req["path"] == "/hello") {
+		return {"status": 201, "headers": {"Content-Type": "text/plain"}, "body": "hello " + req["query"]["name"] + " " + req["method"] + " " + req["body"]};
+	}
+	if (req["path"] == "/text") {
+		return "just text";
+	}
+	if (req["path"] == "/header") {
+		return req["headers"]["X-Token"];
+	}
+	if (req["path"] == "/bad") {
+		return 1;
+	}
+	throw "boom";
+}`)
+	if isError(handler) {
+		t.Fatalf("handler evaluation failed: %s", handler.Inspect())
+	}
+	srv := httptest.NewServer(httpHandler(e, handler))
+	defer srv.Close()
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`http.post("URL/hello?name=bob", "data")["body"]`, "hello bob POST data"},
+		{`http.get("URL/hello?name=bob")["status"]`, 201},
+		{`http.get("URL/hello?name=bob")["headers"]["Content-Type"]`, "text/plain"},
+		{`http.get("URL/text")["status"]`, 200},
+		{`http.get("URL/text")["body"]`, "just text"},
+		{`http.get("URL/header", {"headers": {"X-Token": "t"}})["body"]`, "t"},
+		{`http.get("URL/bad")["status"]`, 500},
+		{`http.get("URL/boom")["status"]`, 500},
+		{`http.get("URL/boom")["body"]`, "Internal Server Error\n"},
+	}
+
+	for _, tt := range tests {
+		input := strings.ReplaceAll(tt.input, "URL", srv.URL)
+		testValue(t, testEvalWith(e, input), tt.expected)
+	}
+
+	if !strings.Contains(out.String(), "http.serve: GET /boom: boom") {
+		t.Errorf("handler error is not reported. output=%q", out.String())
+	}
+	if !strings.Contains(out.String(), "http.serve: GET /bad: handler must return HASH or STRING, got INTEGER") {
+		t.Errorf("bad response is not reported. output=%q", out.String())
+	}
+}
+
+func TestHTTPServeInvalidAddress(t *testing.T) {
+	e := New(WithNetwork())
+	obj := testEvalWith(e, `http.serve("256.0.0.1:http", fn(req) { "" })`)
+	errObj, ok := obj.(*object.Error)
+	if !ok || errObj.Kind != object.IO_ERROR {
+		t.Fatalf("expected IOError, got %T (%+v)", obj, obj)
+	}
+}
+
+func TestHTTPDisabledByDefault(t *testing.T) {
+	for name, member := range httpModule.Members {
+		obj := testEval(testCall(member.(*object.Builtin)))
+		errObj, ok := obj.(*object.Error)
+		if !ok {
+			t.Fatalf("%s: expected error, got %T (%+v)", name, obj, obj)
+		}
+		if errObj.Kind != object.PERMISSION_ERROR {
+			t.Errorf("%s: wrong error kind. expected=%q, got=%q", name, object.PERMISSION_ERROR, errObj.Kind)
+		}
+		expected := "http." + name + ": network access is not enabled"
+		if errObj.Message != expected {
+			t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package evaluator
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pechorka/plang/object"
+)
+
+func init() {
+	registerBuiltins(introspectionBuiltins)
+}
+
+var introspectionBuiltins = map[string]*object.Builtin{
+	"help": {
+		Params: []string{"value"},
+		Doc:    "Returns description of the builtin, function, struct or module.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			switch arg := args[0].(type) {
+			case *object.Builtin:
+				if arg.Doc == "" {
+					return &object.String{Value: arg.Signature()}
+				}
+				return &object.String{Value: arg.Signature() + "\n" + arg.Doc}
+			case *object.Function:
+				return &object.String{Value: functionSignature(arg)}
+			case *object.StructType:
+				return &object.String{Value: arg.Inspect()}
+			case *object.Module:
+				return &object.String{Value: moduleHelp(arg)}
+			}
+			return newTypeError("argument to `help` must be BUILTIN, FUNCTION, STRUCT or MODULE, got %s", args[0].Type())
+		},
+	},
+	"arity": {
+		Params: []string{"f"},
+		Doc:    "Returns number of arguments of the function or [min, max] for variable number, where max is null, if it is unlimited.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			switch arg := args[0].(type) {
+			case *object.Builtin:
+				min, max := arg.Arity()
+				if min == max {
+					return &object.Integer{Value: int64(min)}
+				}
+				var maxObj object.Object = NULL
+				if max >= 0 {
+					maxObj = &object.Integer{Value: int64(max)}
+				}
+				return &object.Array{Elements: []object.Object{&object.Integer{Value: int64(min)}, maxObj}}
+			case *object.Function:
+				return &object.Integer{Value: int64(len(arg.Parameters))}
+			case *object.StructType:
+				return &object.Integer{Value: int64(len(arg.Fields))}
+			}
+			return newTypeError("argument to `arity` must be BUILTIN, FUNCTION or STRUCT, got %s", args[0].Type())
+		},
+	},
+	"builtins": {
+		Params: []string{},
+		Doc:    "Returns sorted array of names of builtin functions.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			names := make([]string, 0, len(builtins))
+			for name := range builtins {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return stringsToArray(names)
+		},
+	},
+}
+
+func functionSignature(fn *object.Function) string {
+	params := make([]string, len(fn.Parameters))
+	for i, p := range fn.Parameters {
+		params[i] = p.Value
+	}
+	return "fn(" + strings.Join(params, ", ") + ")"
+}
+
+// moduleHelp lists members of the module: signatures of builtins and values of constants.
+func moduleHelp(module *object.Module) string {
+	names := make([]string, 0, len(module.Members))
+	for name := range module.Members {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var out strings.Builder
+	out.WriteString("module " + module.Name)
+	for _, name := range names {
+		out.WriteString("\n  ")
+		switch member := module.Members[name].(type) {
+		case *object.Builtin:
+			out.WriteString(member.Signature())
+		default:
+			out.WriteString(module.Name + "." + name + " = " + member.Inspect())
+		}
+	}
+	return out.String()
+}
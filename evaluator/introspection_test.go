@@ -0,0 +1,108 @@
+package evaluator
+
+import (
+	"testing"
+
+	"github.com/pechorka/plang/object"
+)
+
+func TestIntrospectionBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`help(rest)`, "rest(arr)\nReturns new array without the first element or null, if the array is empty."},
+		{`help(math.sqrt)`, "math.sqrt(x)\nReturns square root of the number."},
+		{`help(fn(a, b) { a + b })`, "fn(a, b)"},
+		{`struct Point { x, y }; help(Point)`, "struct Point { x, y }"},
+		{`starts_with(help(math), "module math")`, true},
+		{`contains(help(math), "  math.clamp(x, lo, hi)")`, true},
+		{`contains(help(math), "  math.pi = 3.14")`, true},
+		{`help(1)`, "argument to `help` must be BUILTIN, FUNCTION, STRUCT or MODULE, got INTEGER"},
+		{`help(read_file)`, "read_file(path)\nReturns content of the file."},
+		{`arity(push)`, 2},
+		{`arity(now)`, 0},
+		{`arity(reduce)`, []interface{}{2, 3}},
+		{`arity(puts)`, []interface{}{0, nil}},
+		{`arity(zip)`, []interface{}{1, nil}},
+		{`arity(fn(a, b, c) { a })`, 3},
+		{`struct Point { x, y }; arity(Point)`, 2},
+		{`arity("len")`, "argument to `arity` must be BUILTIN, FUNCTION or STRUCT, got STRING"},
+		{`type(len)`, "BUILTIN"},
+		{`type(math)`, "MODULE"},
+		{`contains(join(builtins(), " "), "help")`, true},
+		{`len(filter(builtins(), fn(name) { name == "read_file" }))`, 1},
+		{`let b = builtins(); first(b) == first(sort(b))`, true},
+	}
+
+	for _, tt := range tests {
+		testValue(t, testEval(tt.input), tt.expected)
+	}
+
+	if got := testEval(`len`).Inspect(); got != "builtin len(value)" {
+		t.Errorf("wrong inspect of builtin. got=%q", got)
+	}
+}
+
+func TestArityIsCheckedCentrally(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`len()`, "wrong number of arguments. got=0, want=1"},
+		{`len([], [])`, "wrong number of arguments. got=2, want=1"},
+		{`random(1)`, "wrong number of arguments. got=1, want=0"},
+		{`trim()`, "wrong number of arguments. got=0, want=1 or 2"},
+		{`parse_time("a")`, "wrong number of arguments. got=1, want=2 or 3"},
+		{`channel(1, 2)`, "wrong number of arguments. got=2, want=0 or 1"},
+		{`sprintf()`, "wrong number of arguments. got=0, want at least 1"},
+		{`math.sqrt(1, 2)`, "wrong number of arguments. got=2, want=1"},
+		{`map([1], push)`, "wrong number of arguments. got=1, want=2"},
+	}
+
+	for _, tt := range tests {
+		obj := testEval(tt.input)
+		errObj, ok := obj.(*object.Error)
+		if !ok {
+			t.Errorf("%s: expected error, got %T (%+v)", tt.input, obj, obj)
+			continue
+		}
+		if errObj.Kind != object.ARGUMENT_ERROR || errObj.Message != tt.expected {
+			t.Errorf("%s: wrong error. expected=%q, got=%s: %q", tt.input, tt.expected, errObj.Kind, errObj.Message)
+		}
+	}
+}
+
+func TestBuiltinsHaveMetadata(t *testing.T) {
+	all := map[string]*object.Builtin{}
+	for name, b := range builtins {
+		all[name] = b
+	}
+	for _, m := range []map[string]*object.Builtin{
+		nameBuiltins((&sandboxFS{}).builtins()),
+		nameBuiltins(processBuiltins(nil)),
+	} {
+		for name, b := range m {
+			all["enabled "+name] = b
+		}
+	}
+	for _, module := range []*object.Module{mathModule, httpModule, modules["http"]} {
+		for _, member := range module.Members {
+			if b, ok := member.(*object.Builtin); ok {
+				all[b.Name] = b
+			}
+		}
+	}
+
+	for key, b := range all {
+		if b.Name == "" {
+			t.Errorf("%s: builtin has no name", key)
+		}
+		if b.Doc == "" {
+			t.Errorf("%s: builtin has no doc", key)
+		}
+		if b.Params == nil {
+			t.Errorf("%s: builtin has no params", key)
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package evaluator
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pechorka/plang/object"
+)
+
+func init() {
+	registerBuiltins(jsonBuiltins)
+}
+
+var jsonBuiltins = map[string]*object.Builtin{
+	"json_encode": {
+		Params: []string{"value", "indent?"},
+		Doc:    "Encodes the value as JSON. Indent is a number of spaces or a string.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			var buf bytes.Buffer
+			if err := encodeJSON(&buf, args[0], "$"); err != nil {
+				return err
+			}
+			if len(args) == 1 {
+				return &object.String{Value: buf.String()}
+			}
+			indent, err := jsonIndent(args[1])
+			if err != nil {
+				return err
+			}
+			var out bytes.Buffer
+			// buf holds valid json produced by encodeJSON, so Indent can't fail
+			_ = json.Indent(&out, buf.Bytes(), "", indent)
+			return &object.String{Value: out.String()}
+		},
+	},
+	"json_decode": {
+		Params: []string{"str"},
+		Doc:    "Decodes the JSON string.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			str, ok := args[0].(*object.String)
+			if !ok {
+				return newTypeError("argument to `json_decode` must be STRING, got %s", args[0].Type())
+			}
+			return decodeJSON(str.Value)
+		},
+	},
+}
+
+// encodeJSON writes compact json representation of obj to buf.
+// path is a location of obj inside the encoded value, used in error messages.
+// Keys of hashes are written in sorted order, so output is deterministic.
+func encodeJSON(buf *bytes.Buffer, obj object.Object, path string) *object.Error {
+	switch obj := obj.(type) {
+	case *object.Null:
+		buf.WriteString("null")
+	case *object.Boolean:
+		buf.WriteString(strconv.FormatBool(obj.Value))
+	case *object.Integer:
+		buf.WriteString(strconv.FormatInt(obj.Value, 10))
+	case *object.Float:
+		if math.IsNaN(obj.Value) || math.IsInf(obj.Value, 0) {
+			return newTypeError("json_encode: cannot encode %s at %s", obj.Inspect(), path)
+		}
+		f := strconv.FormatFloat(obj.Value, 'g', -1, 64)
+		if !strings.ContainsAny(f, ".e") {
+			f += ".0" // integral float is decoded as float too
+		}
+		buf.WriteString(f)
+	case *object.String:
+		writeJSONString(buf, obj.Value)
+	case *object.Array:
+		buf.WriteByte('[')
+		for i, el := range obj.Elements {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := encodeJSON(buf, el, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case *object.Hash:
+		keys := make([]string, 0, len(obj.Pairs))
+		values := make(map[string]object.Object, len(obj.Pairs))
+		for _, pair := range obj.SortedPairs() {
+			key, ok := pair.Key.(*object.String)
+			if !ok {
+				return newTypeError("json_encode: object key must be STRING, got %s (%s) at %s",
+					pair.Key.Type(), pair.Key.Inspect(), path)
+			}
+			keys = append(keys, key.Value)
+			values[key.Value] = pair.Value
+		}
+		sort.Strings(keys)
+		buf.WriteByte('{')
+		for i, key := range keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeJSONString(buf, key)
+			buf.WriteByte(':')
+			if err := encodeJSON(buf, values[key], jsonMemberPath(path, key)); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	default:
+		return newTypeError("json_encode: cannot encode %s at %s", obj.Type(), path)
+	}
+	return nil
+}
+
+// writeJSONString writes the quoted string. Unlike json.Marshal, it keeps <, > and & as is.
+func writeJSONString(buf *bytes.Buffer, s string) {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	// encoding of a string never fails
+	_ = enc.Encode(s)
+	buf.Write(bytes.TrimSuffix(b.Bytes(), []byte("\n")))
+}
+
+// jsonMemberPath appends key to the path as .key,
+// or as ["key"] if the key is not a plain identifier.
+func jsonMemberPath(path, key string) string {
+	plain := key != ""
+	for _, r := range key {
+		if !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' || r == '_') {
+			plain = false
+			break
+		}
+	}
+	if plain {
+		return path + "." + key
+	}
+	return path + "[" + strconv.Quote(key) + "]"
+}
+
+// jsonIndent accepts number of spaces or indentation string.
+func jsonIndent(obj object.Object) (string, *object.Error) {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		if obj.Value < 0 || obj.Value > 16 {
+			return "", newArgumentError("json_encode: indent must be between 0 and 16, got %d", obj.Value)
+		}
+		return strings.Repeat(" ", int(obj.Value)), nil
+	case *object.String:
+		return obj.Value, nil
+	}
+	return "", newTypeError("second argument to `json_encode` must be INTEGER or STRING, got %s", obj.Type())
+}
+
+// decodeJSON parses a single json value. Objects become hashes with string keys,
+// integral numbers become integers, other numbers become floats.
+func decodeJSON(input string) object.Object {
+	dec := json.NewDecoder(strings.NewReader(input))
+	dec.UseNumber()
+	var val interface{}
+	if err := dec.Decode(&val); err != nil {
+		return jsonDecodeError(input, err)
+	}
+	end := dec.InputOffset()
+	if _, err := dec.Token(); err != io.EOF {
+		rest := input[end:]
+		end += int64(len(rest) - len(strings.TrimLeft(rest, " \t\r\n")))
+		line, col := jsonPosition(input, end)
+		return newArgumentError("json_decode: unexpected data after top-level value at line %d, column %d", line, col)
+	}
+	return jsonToObject(val)
+}
+
+func jsonDecodeError(input string, err error) *object.Error {
+	switch err := err.(type) {
+	case *json.SyntaxError:
+		line, col := jsonPosition(input, err.Offset)
+		return newArgumentError("json_decode: %s at line %d, column %d", err, line, col)
+	}
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return newArgumentError("json_decode: unexpected end of input")
+	}
+	return newArgumentError("json_decode: %s", err)
+}
+
+// jsonPosition converts byte offset to 1-based line and rune column.
+func jsonPosition(input string, offset int64) (line, col int) {
+	if offset > int64(len(input)) {
+		offset = int64(len(input))
+	}
+	before := input[:offset]
+	line = strings.Count(before, "\n") + 1
+	lineStart := strings.LastIndexByte(before, '\n') + 1
+	col = len([]rune(before[lineStart:])) + 1
+	return line, col
+}
+
+func jsonToObject(val interface{}) object.Object {
+	switch val := val.(type) {
+	case nil:
+		return NULL
+	case bool:
+		return boolToBooleanObject(val)
+	case string:
+		return &object.String{Value: val}
+	case json.Number:
+		if i, err := strconv.ParseInt(string(val), 10, 64); err == nil {
+			return &object.Integer{Value: i}
+		}
+		f, err := strconv.ParseFloat(string(val), 64)
+		if err != nil {
+			return newArgumentError("json_decode: number %s is out of range", val)
+		}
+		return &object.Float{Value: f}
+	case []interface{}:
+		elems := make([]object.Object, len(val))
+		for i, el := range val {
+			elems[i] = jsonToObject(el)
+			if isError(elems[i]) {
+				return elems[i]
+			}
+		}
+		return &object.Array{Elements: elems}
+	case map[string]interface{}:
+		pairs := make(map[object.HashKey]object.HashPair, len(val))
+		for k, v := range val {
+			key := &object.String{Value: k}
+			value := jsonToObject(v)
+			if isError(value) {
+				return value
+			}
+			pairs[key.HashKey()] = object.HashPair{Key: key, Value: value}
+		}
+		return &object.Hash{Pairs: pairs}
+	}
+	return newError("json_decode: unexpected value %v", val)
+}
@@ -0,0 +1,86 @@
+package evaluator
+
+import (
+	"testing"
+
+	"github.com/pechorka/plang/object"
+)
+
+func TestJSONEncode(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`json_encode(1)`, "1"},
+		{`json_encode(1.5)`, "1.5"},
+		{`json_encode(1.0)`, "1.0"},
+		{`json_encode(-0.0)`, "-0.0"},
+		{`json_encode(json_decode("1e21"))`, "1e+21"},
+		{`json_encode("a<b && c>d")`, `"a<b && c>d"`},
+		{`json_encode({"<": "&"})`, `{"<":"&"}`},
+		{`json_encode("при	вет")`, `"при\tвет"`},
+		{`json_encode([1, true, "a", [], {}])`, `[1,true,"a",[],{}]`},
+		{`json_encode(first([]))`, "null"},
+		{`json_encode({"b": 1, "a": [2]})`, `{"a":[2],"b":1}`},
+		{`json_encode({"a": [1, 2]}, 2)`, "{\n  \"a\": [\n    1,\n    2\n  ]\n}"},
+		{`json_encode([1], "	")`, "[\n\t1\n]"},
+		{`json_encode([1], -1)`, "json_encode: indent must be between 0 and 16, got -1"},
+		{`json_encode([1], true)`, "second argument to `json_encode` must be INTEGER or STRING, got BOOLEAN"},
+		{`json_encode({"a": [1, fn(x) { x }]})`, "json_encode: cannot encode FUNCTION at $.a[1]"},
+		{`json_encode({"my key": {1: 2}})`, `json_encode: object key must be STRING, got INTEGER (1) at $["my key"]`},
+		{`json_encode(math.sqrt)`, "json_encode: cannot encode BUILTIN at $"},
+	}
+
+	for _, tt := range tests {
+		testValue(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestJSONDecode(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`42`, 42},
+		{`-1.25`, -1.25},
+		{`1e3`, 1000.0},
+		{`true`, true},
+		{` null `, nil},
+		{`[1, 2, 3]`, []int64{1, 2, 3}},
+		{`"при\u0432ет"`, "привет"},
+		{`[1, 2`, "json_decode: unexpected end of input"},
+		{``, "json_decode: unexpected end of input"},
+		{"[1,\n x]", "json_decode: invalid character 'x' looking for beginning of value at line 2, column 3"},
+		{`1 2`, "json_decode: unexpected data after top-level value at line 1, column 3"},
+		{`100000000000000000000`, 1e20},
+		{`1e400`, "json_decode: number 1e400 is out of range"},
+	}
+
+	for _, tt := range tests {
+		testValue(t, decodeJSON(tt.input), tt.expected)
+	}
+}
+
+func TestJSONDecodeObject(t *testing.T) {
+	obj := decodeJSON(`{"a": {"b": "при"}, "c": [true, null]}`)
+	hash, ok := obj.(*object.Hash)
+	if !ok {
+		t.Fatalf("object is not Hash. got=%T (%+v)", obj, obj)
+	}
+	if len(hash.Pairs) != 2 {
+		t.Fatalf("hash has wrong number of pairs. got=%d", len(hash.Pairs))
+	}
+	a := hash.Pairs[(&object.String{Value: "a"}).HashKey()].Value
+	b := a.(*object.Hash).Pairs[(&object.String{Value: "b"}).HashKey()].Value
+	testValue(t, b, "при")
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	input := `let v = {"name": "plang", "tags": ["a", "b"], "ok": true, "n": 1.5};
+json_encode(json_decode(json_encode(v))) == json_encode(v)`
+	testValue(t, testEval(input), true)
+
+	testValue(t, testEval(`json_decode(json_encode({"a": [1, 2]}))["a"][1]`), 2)
+	testValue(t, testEval(`json_decode(json_encode([1.0, 1]))`), []interface{}{1.0, 1})
+	testValue(t, testEval(`json_decode(1)`), "argument to `json_decode` must be STRING, got INTEGER")
+}
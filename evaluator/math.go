@@ -0,0 +1,289 @@
+package evaluator
+
+import (
+	"math"
+
+	"github.com/pechorka/plang/object"
+)
+
+func init() {
+	registerModule(mathModule)
+}
+
+// Functions of the math module accept both integers and floats.
+// Integer results are returned where the operation is closed over integers,
+// and overflowing them is an error rather than a silent wrap around.
+var mathModule = newModule("math", map[string]object.Object{
+	"pi": &object.Float{Value: math.Pi},
+	"e":  &object.Float{Value: math.E},
+	"abs": &object.Builtin{
+		Params: []string{"x"},
+		Doc:    "Returns absolute value of the number.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			switch arg := args[0].(type) {
+			case *object.Integer:
+				if arg.Value == math.MinInt64 {
+					return newError("integer overflow in `math.abs`")
+				}
+				if arg.Value < 0 {
+					return &object.Integer{Value: -arg.Value}
+				}
+				return arg
+			case *object.Float:
+				return &object.Float{Value: math.Abs(arg.Value)}
+			}
+			return newTypeError("argument to `math.abs` must be INTEGER or FLOAT, got %s", args[0].Type())
+		},
+	},
+	"pow": &object.Builtin{
+		Params: []string{"x", "y"},
+		Doc:    "Returns x to the power of y. The result is an integer for integer arguments and non-negative y.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			base, baseOk := args[0].(*object.Integer)
+			exp, expOk := args[1].(*object.Integer)
+			if baseOk && expOk && exp.Value >= 0 {
+				res, ok := powInt(base.Value, exp.Value)
+				if !ok {
+					return newError("integer overflow in `math.pow`")
+				}
+				return &object.Integer{Value: res}
+			}
+			x, err := numberArg("pow", args, 0)
+			if err != nil {
+				return err
+			}
+			y, err := numberArg("pow", args, 1)
+			if err != nil {
+				return err
+			}
+			res := math.Pow(x, y)
+			if math.IsNaN(res) || math.IsInf(res, 0) {
+				return newArgumentError("math domain error: `math.pow` of %s and %s",
+					args[0].Inspect(), args[1].Inspect())
+			}
+			return &object.Float{Value: res}
+		},
+	},
+	"sqrt":  floatBuiltin("sqrt", "Returns square root of the number.", math.Sqrt, func(x float64) bool { return x >= 0 }),
+	"floor": roundingBuiltin("floor", "Returns the greatest integer less than or equal to the number.", math.Floor),
+	"ceil":  roundingBuiltin("ceil", "Returns the least integer greater than or equal to the number.", math.Ceil),
+	"round": roundingBuiltin("round", "Returns the nearest integer, rounding half away from zero.", math.Round),
+	"min": &object.Builtin{
+		Params: []string{"values..."},
+		Doc:    "Returns the smallest of numbers given as arguments or as an array.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			return extremum("min", -1, args)
+		},
+	},
+	"max": &object.Builtin{
+		Params: []string{"values..."},
+		Doc:    "Returns the greatest of numbers given as arguments or as an array.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			return extremum("max", 1, args)
+		},
+	},
+	"sum": &object.Builtin{
+		Params: []string{"arr"},
+		Doc:    "Returns sum of numbers of the array.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			arr, ok := args[0].(*object.Array)
+			if !ok {
+				return newTypeError("argument to `math.sum` must be ARRAY, got %s", args[0].Type())
+			}
+			var (
+				intSum   int64
+				floatSum float64
+				isFloat  bool
+			)
+			for i, el := range arr.Elements {
+				switch el := el.(type) {
+				case *object.Integer:
+					if !isFloat {
+						res, ok := addInt(intSum, el.Value)
+						if !ok {
+							return newError("integer overflow in `math.sum`")
+						}
+						intSum = res
+						continue
+					}
+					floatSum += float64(el.Value)
+				case *object.Float:
+					if !isFloat {
+						isFloat = true
+						floatSum = float64(intSum)
+					}
+					floatSum += el.Value
+				default:
+					return newTypeError("`math.sum` expects array of numbers, got %s at index %d", el.Type(), i)
+				}
+			}
+			if isFloat {
+				return &object.Float{Value: floatSum}
+			}
+			return &object.Integer{Value: intSum}
+		},
+	},
+	"clamp": &object.Builtin{
+		Params: []string{"x", "lo", "hi"},
+		Doc:    "Limits the number to the range [lo, hi].",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			for i := range args {
+				if _, err := numberArg("clamp", args, i); err != nil {
+					return err
+				}
+			}
+			x, lo, hi := args[0], args[1], args[2]
+			if cmp, _ := compareObjects(lo, hi); cmp > 0 {
+				return newArgumentError("`math.clamp` lower bound %s is greater than upper bound %s",
+					lo.Inspect(), hi.Inspect())
+			}
+			if cmp, _ := compareObjects(x, lo); cmp < 0 {
+				return lo
+			}
+			if cmp, _ := compareObjects(x, hi); cmp > 0 {
+				return hi
+			}
+			return x
+		},
+	},
+	"sin":  floatBuiltin("sin", "Returns sine of the angle in radians.", math.Sin, nil),
+	"cos":  floatBuiltin("cos", "Returns cosine of the angle in radians.", math.Cos, nil),
+	"tan":  floatBuiltin("tan", "Returns tangent of the angle in radians.", math.Tan, nil),
+	"asin": floatBuiltin("asin", "Returns arc sine in radians.", math.Asin, func(x float64) bool { return x >= -1 && x <= 1 }),
+	"acos": floatBuiltin("acos", "Returns arc cosine in radians.", math.Acos, func(x float64) bool { return x >= -1 && x <= 1 }),
+	"atan": floatBuiltin("atan", "Returns arc tangent in radians.", math.Atan, nil),
+	"atan2": &object.Builtin{
+		Params: []string{"y", "x"},
+		Doc:    "Returns arc tangent of y/x, using signs of both to determine the quadrant.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			y, err := numberArg("atan2", args, 0)
+			if err != nil {
+				return err
+			}
+			x, err := numberArg("atan2", args, 1)
+			if err != nil {
+				return err
+			}
+			return &object.Float{Value: math.Atan2(y, x)}
+		},
+	},
+})
+
+// floatBuiltin wraps one argument float function.
+// inDomain, if not nil, reports whether the argument is valid.
+func floatBuiltin(name, doc string, f func(float64) float64, inDomain func(float64) bool) *object.Builtin {
+	return &object.Builtin{
+		Params: []string{"x"},
+		Doc:    doc,
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			x, err := numberArg(name, args, 0)
+			if err != nil {
+				return err
+			}
+			if inDomain != nil && !inDomain(x) {
+				return newArgumentError("math domain error: `math.%s` of %s", name, args[0].Inspect())
+			}
+			return &object.Float{Value: f(x)}
+		},
+	}
+}
+
+// roundingBuiltin wraps rounding function, which result is converted to integer.
+func roundingBuiltin(name, doc string, round func(float64) float64) *object.Builtin {
+	return &object.Builtin{
+		Params: []string{"x"},
+		Doc:    doc,
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			if i, ok := args[0].(*object.Integer); ok {
+				return i
+			}
+			x, err := numberArg(name, args, 0)
+			if err != nil {
+				return err
+			}
+			res := round(x)
+			// float64(math.MaxInt64) is 2^63, which does not fit into int64
+			if math.IsNaN(res) || res < math.MinInt64 || res >= math.MaxInt64 {
+				return newError("integer overflow in `math.%s` of %s", name, args[0].Inspect())
+			}
+			return &object.Integer{Value: int64(res)}
+		},
+	}
+}
+
+// extremum returns the smallest (sign -1) or the greatest (sign 1) number
+// from arguments or from the single array argument.
+func extremum(name string, sign int, args []object.Object) object.Object {
+	if len(args) == 1 {
+		if arr, ok := args[0].(*object.Array); ok {
+			args = arr.Elements
+		}
+	}
+	if len(args) == 0 {
+		return newArgumentError("`math.%s` of empty sequence", name)
+	}
+	var best object.Object
+	for i := range args {
+		if _, err := numberArg(name, args, i); err != nil {
+			return err
+		}
+		if cmp, _ := compareObjects(args[i], best); best == nil || cmp == sign {
+			best = args[i]
+		}
+	}
+	return best
+}
+
+func numberArg(name string, args []object.Object, i int) (float64, *object.Error) {
+	x, ok := toFloat(args[i])
+	if !ok {
+		return 0, newTypeError("argument to `math.%s` must be INTEGER or FLOAT, got %s", name, args[i].Type())
+	}
+	return x, nil
+}
+
+// powInt computes base**exp by squaring. ok is false on overflow.
+func powInt(base, exp int64) (res int64, ok bool) {
+	res = 1
+	for exp > 0 {
+		if exp&1 == 1 {
+			if res, ok = mulInt(res, base); !ok {
+				return 0, false
+			}
+		}
+		exp >>= 1
+		if exp > 0 {
+			if base, ok = mulInt(base, base); !ok {
+				return 0, false
+			}
+		}
+	}
+	return res, true
+}
+
+func addInt(a, b int64) (int64, bool) {
+	c := a + b
+	if (c > a) != (b > 0) {
+		return 0, false
+	}
+	return c, true
+}
+
+func subInt(a, b int64) (int64, bool) {
+	c := a - b
+	if (c < a) != (b > 0) {
+		return 0, false
+	}
+	return c, true
+}
+
+func mulInt(a, b int64) (int64, bool) {
+	if a == 0 || b == 0 {
+		return 0, true
+	}
+	c := a * b
+	if c/b != a || (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+		return 0, false
+	}
+	return c, true
+}
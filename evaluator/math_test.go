@@ -0,0 +1,66 @@
+package evaluator
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMathModule(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`math.pi`, math.Pi},
+		{`math.e`, math.E},
+		{`math.abs(-5)`, 5},
+		{`math.abs(-2.5)`, 2.5},
+		{`math.abs(-9223372036854775807 - 1)`, "integer overflow in `math.abs`"},
+		{`math.abs("a")`, "argument to `math.abs` must be INTEGER or FLOAT, got STRING"},
+		{`math.pow(2, 10)`, 1024},
+		{`math.pow(2, -1)`, 0.5},
+		{`math.pow(4, 0.5)`, 2.0},
+		{`math.pow(2, 63)`, "integer overflow in `math.pow`"},
+		{`math.pow(-8, 0.5)`, "math domain error: `math.pow` of -8 and 0.5"},
+		{`math.sqrt(16)`, 4.0},
+		{`math.sqrt(-1)`, "math domain error: `math.sqrt` of -1"},
+		{`math.floor(2.7)`, 2},
+		{`math.floor(-2.5)`, -3},
+		{`math.ceil(2.1)`, 3},
+		{`math.round(2.5)`, 3},
+		{`math.round(7)`, 7},
+		{`math.round(math.pow(10.0, 20))`, "integer overflow in `math.round` of 1e+20"},
+		{`math.min(3, 1.5, 2)`, 1.5},
+		{`math.max([3, 1, 2])`, 3},
+		{`math.max([])`, "`math.max` of empty sequence"},
+		{`math.min(1, "a")`, "argument to `math.min` must be INTEGER or FLOAT, got STRING"},
+		{`math.sum([1, 2, 3])`, 6},
+		{`math.sum([1, 2.5])`, 3.5},
+		{`math.sum([])`, 0},
+		{`math.sum([9223372036854775807, 1])`, "integer overflow in `math.sum`"},
+		{`math.sum([1, "a"])`, "`math.sum` expects array of numbers, got STRING at index 1"},
+		{`math.clamp(5, 0, 3)`, 3},
+		{`math.clamp(-1, 0, 3)`, 0},
+		{`math.clamp(1.5, 0, 3)`, 1.5},
+		{`math.clamp(1, 3, 0)`, "`math.clamp` lower bound 3 is greater than upper bound 0"},
+		{`math.sin(0)`, 0.0},
+		{`math.cos(math.pi)`, -1.0},
+		{`math.tan(0)`, 0.0},
+		{`math.asin(1)`, math.Pi / 2},
+		{`math.acos(2)`, "math domain error: `math.acos` of 2"},
+		{`math.atan(1)`, math.Pi / 4},
+		{`math.atan2(1, 1)`, math.Pi / 4},
+		{`math.atan2(1, -1)`, 3 * math.Pi / 4},
+		{`math.cbrt(8)`, "unknown member cbrt of module math"},
+		{`type(math)`, "MODULE"},
+		{`let math = 1; math`, 1},
+	}
+
+	for _, tt := range tests {
+		testValue(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestMathErrorsAreCatchable(t *testing.T) {
+	input := `try { math.sqrt(-4) } catch (e) { e["kind"] }`
+	testValue(t, testEval(input), "ArgumentError")
+}
@@ -0,0 +1,199 @@
+package evaluator
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pechorka/plang/object"
+)
+
+func init() {
+	registerBuiltins(printBuiltins)
+}
+
+// Output builtins show strings as is and other values like literals in the source:
+// strings nested in arrays and hashes are quoted, and hash keys are sorted.
+var printBuiltins = map[string]*object.Builtin{
+	"print": {
+		Params: []string{"values..."},
+		Doc:    "Writes values separated with spaces.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			return writeOutput(in, "print", joinDisplay(args))
+		},
+	},
+	"println": {
+		Params: []string{"values..."},
+		Doc:    "Writes values separated with spaces and a newline.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			return writeOutput(in, "println", joinDisplay(args)+"\n")
+		},
+	},
+	"printf": {
+		Params: []string{"format", "args..."},
+		Doc:    "Writes arguments formatted according to the format, see sprintf.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			str, err := sprintf("printf", args)
+			if err != nil {
+				return err
+			}
+			return writeOutput(in, "printf", str)
+		},
+	},
+	"sprintf": {
+		Params: []string{"format", "args..."},
+		Doc:    "Returns arguments formatted according to the format. Verbs follow Go fmt: %v %s %q %d %x %X %o %b %c %f %e %g %t.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			str, err := sprintf("sprintf", args)
+			if err != nil {
+				return err
+			}
+			return &object.String{Value: str}
+		},
+	},
+}
+
+func writeOutput(in object.Interpreter, name, str string) object.Object {
+	if _, err := io.WriteString(in.Output(), str); err != nil {
+		return newErrorOfKind(object.IO_ERROR, "%s: %s", name, err)
+	}
+	return NULL
+}
+
+// joinDisplay joins displayed values with spaces.
+func joinDisplay(args []object.Object) string {
+	parts := make([]string, len(args))
+	for i, arg := range args {
+		parts[i] = display(arg)
+	}
+	return strings.Join(parts, " ")
+}
+
+// display shows strings without quotes and everything else as repr.
+func display(obj object.Object) string {
+	if str, ok := obj.(*object.String); ok {
+		return str.Value
+	}
+	return repr(obj)
+}
+
+// repr shows value like a literal in the source.
+func repr(obj object.Object) string {
+	switch obj := obj.(type) {
+	case *object.String:
+		return strconv.Quote(obj.Value)
+	case *object.Array:
+		parts := make([]string, len(obj.Elements))
+		for i, el := range obj.Elements {
+			parts[i] = repr(el)
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	case *object.Hash:
+		pairs := obj.SortedPairs()
+		parts := make([]string, len(pairs))
+		for i, pair := range pairs {
+			parts[i] = repr(pair.Key) + ": " + repr(pair.Value)
+		}
+		return "{" + strings.Join(parts, ", ") + "}"
+	}
+	return obj.Inspect()
+}
+
+// sprintf formats args[1:] according to the format in args[0].
+// Verbs follow Go fmt, with flags, width and precision, and accept plang types:
+//
+//	%v       any value, as print shows it
+//	%s       any value, as print shows it
+//	%q       string, quoted
+//	%d       integer
+//	%x %X    integer or string
+//	%o %b %c integer
+//	%f %e %g integer or float
+//	%t       boolean
+//	%%       percent sign
+func sprintf(name string, args []object.Object) (string, *object.Error) {
+	format, ok := args[0].(*object.String)
+	if !ok {
+		return "", newTypeError("first argument to `%s` must be STRING, got %s", name, args[0].Type())
+	}
+	args = args[1:]
+
+	var out strings.Builder
+	str := format.Value
+	argIdx := 0
+	for i := 0; i < len(str); i++ {
+		if str[i] != '%' {
+			out.WriteByte(str[i])
+			continue
+		}
+		start := i
+		i++
+		for i < len(str) && strings.IndexByte("+-# 0123456789.", str[i]) >= 0 {
+			i++
+		}
+		if i >= len(str) {
+			return "", newArgumentError("%s: unterminated verb %q", name, str[start:])
+		}
+		if str[i] >= utf8.RuneSelf {
+			r, _ := utf8.DecodeRuneInString(str[i:])
+			return "", newArgumentError("%s: unknown verb %%%c", name, r)
+		}
+		spec, verb := str[start:i+1], str[i]
+		if verb == '%' {
+			out.WriteByte('%')
+			continue
+		}
+		if argIdx >= len(args) {
+			return "", newArgumentError("%s: missing argument for %s", name, spec)
+		}
+		val, err := formatArg(name, verb, args[argIdx])
+		if err != nil {
+			return "", err
+		}
+		argIdx++
+		out.WriteString(fmt.Sprintf(spec, val))
+	}
+	if argIdx < len(args) {
+		return "", newArgumentError("%s: %d extra arguments", name, len(args)-argIdx)
+	}
+	return out.String(), nil
+}
+
+// formatArg converts arg to Go value, that fmt formats with the verb.
+func formatArg(name string, verb byte, arg object.Object) (interface{}, *object.Error) {
+	switch verb {
+	case 'v', 's':
+		return display(arg), nil
+	case 'q':
+		if str, ok := arg.(*object.String); ok {
+			return str.Value, nil
+		}
+		return nil, newTypeError("%s: %%q expects STRING, got %s", name, arg.Type())
+	case 'd', 'o', 'b', 'c':
+		if i, ok := arg.(*object.Integer); ok {
+			return i.Value, nil
+		}
+		return nil, newTypeError("%s: %%%c expects INTEGER, got %s", name, verb, arg.Type())
+	case 'x', 'X':
+		switch arg := arg.(type) {
+		case *object.Integer:
+			return arg.Value, nil
+		case *object.String:
+			return arg.Value, nil
+		}
+		return nil, newTypeError("%s: %%%c expects INTEGER or STRING, got %s", name, verb, arg.Type())
+	case 'f', 'e', 'g', 'E', 'G':
+		if f, ok := toFloat(arg); ok {
+			return f, nil
+		}
+		return nil, newTypeError("%s: %%%c expects INTEGER or FLOAT, got %s", name, verb, arg.Type())
+	case 't':
+		if b, ok := arg.(*object.Boolean); ok {
+			return b.Value, nil
+		}
+		return nil, newTypeError("%s: %%t expects BOOLEAN, got %s", name, arg.Type())
+	}
+	return nil, newArgumentError("%s: unknown verb %%%c", name, verb)
+}
@@ -0,0 +1,67 @@
+package evaluator
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestOutputBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`print("a", 1); print("b")`, "a 1b"},
+		{`println("при", "вет")`, "при вет\n"},
+		{`println()`, "\n"},
+		{`println(["a", 1, [true]], {"b": "x", "a": 2})`, "[\"a\", 1, [true]] {\"a\": 2, \"b\": \"x\"}\n"},
+		{`println(1.0, duration("1s"))`, "1.0 1s\n"},
+		{`printf("%s=%05.2f;", "pi", math.pi)`, "pi=03.14;"},
+		{`puts("a", 1)`, "a\n1\n"},
+		{`let t = spawn fn() { print("x") }; wait(t); print("y")`, "xy"},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		obj := testEvalWith(New(WithOutput(&out)), tt.input)
+		if isError(obj) {
+			t.Errorf("unexpected error for %q: %s", tt.input, obj.Inspect())
+			continue
+		}
+		if out.String() != tt.expected {
+			t.Errorf("wrong output of %q. expected=%q, got=%q", tt.input, tt.expected, out.String())
+		}
+	}
+}
+
+func TestSprintf(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`sprintf("plain")`, "plain"},
+		{`sprintf("%d|%5d|%-3d|%+d", 1, 42, 7, 3)`, "1|   42|7  |+3"},
+		{`sprintf("%x %X %o %b", 255, 255, 8, 5)`, "ff FF 10 101"},
+		{`sprintf("%x", "hi")`, "6869"},
+		{`sprintf("%c", 1099)`, "ы"},
+		{`sprintf("%.3f %e %g", 2, 1500.0, 0.5)`, "2.000 1.500000e+03 0.5"},
+		{`sprintf("%t", true)`, "true"},
+		{`sprintf("%q", "a b")`, `"a b"`},
+		{`sprintf("%v and %s", [1, "a"], "a")`, `[1, "a"] and a`},
+		{`sprintf("%6s|%-6s|", "при", "ab")`, "   при|ab    |"},
+		{`sprintf("100%%")`, "100%"},
+		{`sprintf("%d", "a")`, "sprintf: %d expects INTEGER, got STRING"},
+		{`sprintf("%f", true)`, "sprintf: %f expects INTEGER or FLOAT, got BOOLEAN"},
+		{`sprintf("%d %d", 1)`, "sprintf: missing argument for %d"},
+		{`sprintf("%d", 1, 2)`, "sprintf: 1 extra arguments"},
+		{`sprintf("%z", 1)`, "sprintf: unknown verb %z"},
+		{`sprintf("%ы", 1)`, "sprintf: unknown verb %ы"},
+		{`sprintf("abc %5")`, `sprintf: unterminated verb "%5"`},
+		{`sprintf(1)`, "first argument to `sprintf` must be STRING, got INTEGER"},
+		{`sprintf()`, "wrong number of arguments. got=0, want at least 1"},
+		{`printf("%d", "x")`, "printf: %d expects INTEGER, got STRING"},
+	}
+
+	for _, tt := range tests {
+		testValue(t, testEval(tt.input), tt.expected)
+	}
+}
@@ -0,0 +1,171 @@
+package evaluator
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/pechorka/plang/object"
+)
+
+func init() {
+	registerBuiltins(disabledBuiltins("process", processBuiltins(nil)))
+}
+
+// WithProcess grants scripts access to the command line arguments, environment variables,
+// exit status and running other programs. Programs are not sandboxed in any way,
+// so the capability gives scripts all permissions of the host process.
+func WithProcess(args []string) Option {
+	return func(e *Evaluator) {
+		for name, b := range nameBuiltins(processBuiltins(args)) {
+			e.builtins[name] = b
+		}
+	}
+}
+
+func processBuiltins(scriptArgs []string) map[string]*object.Builtin {
+	return map[string]*object.Builtin{
+		"args": {
+			Params: []string{},
+			Doc:    "Returns array of command line arguments of the script.",
+			Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+				return stringsToArray(scriptArgs)
+			},
+		},
+		"env": {
+			Params: []string{"name"},
+			Doc:    "Returns value of the environment variable or null, if it is not set.",
+			Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+				name, ok := args[0].(*object.String)
+				if !ok {
+					return newTypeError("argument to `env` must be STRING, got %s", args[0].Type())
+				}
+				val, ok := os.LookupEnv(name.Value)
+				if !ok {
+					return NULL
+				}
+				return &object.String{Value: val}
+			},
+		},
+		"exit": {
+			Params: []string{"code?"},
+			Doc:    "Stops the script with the exit code, which is 0 by default. It runs finally blocks, but can't be caught.",
+			Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+				code := &object.Integer{Value: 0}
+				if len(args) == 1 {
+					var ok bool
+					code, ok = args[0].(*object.Integer)
+					if !ok {
+						return newTypeError("argument to `exit` must be INTEGER, got %s", args[0].Type())
+					}
+					if code.Value < 0 || code.Value > 255 {
+						return newArgumentError("exit code must be between 0 and 255, got %d", code.Value)
+					}
+				}
+				return object.NewExit(code.Value)
+			},
+		},
+		"exec": {
+			Params: []string{"cmd", "args?", "options?"},
+			Doc:    "Runs the program and returns {\"stdout\", \"stderr\", \"code\"}. Options are {\"stdin\", \"cwd\", \"env\", \"timeout\"}.",
+			Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+				return execCommand(args)
+			},
+		},
+	}
+}
+
+// execCommand runs exec(cmd, args?, {stdin, cwd, env, timeout}?) and returns
+// {"stdout", "stderr", "code"}. Non-zero exit code is not an error,
+// only failure to start or to wait for the program is.
+// Variables from env are added to the environment of the host process.
+func execCommand(args []object.Object) object.Object {
+	name, ok := args[0].(*object.String)
+	if !ok {
+		return newTypeError("first argument to `exec` must be STRING, got %s", args[0].Type())
+	}
+	var cmdArgs []string
+	if len(args) > 1 {
+		arr, ok := args[1].(*object.Array)
+		if !ok {
+			return newTypeError("second argument to `exec` must be ARRAY, got %s", args[1].Type())
+		}
+		for i, el := range arr.Elements {
+			str, ok := el.(*object.String)
+			if !ok {
+				return newTypeError("`exec` expects array of STRING, got %s at index %d", el.Type(), i)
+			}
+			cmdArgs = append(cmdArgs, str.Value)
+		}
+	}
+
+	ctx := context.Background()
+	var stdin, dir string
+	var env []string
+	if len(args) > 2 {
+		opts, ok := args[2].(*object.Hash)
+		if !ok {
+			return newTypeError("third argument to `exec` must be HASH, got %s", args[2].Type())
+		}
+		if val, ok := hashField(opts, "stdin"); ok {
+			str, ok := val.(*object.String)
+			if !ok {
+				return newTypeError("exec: stdin must be STRING, got %s", val.Type())
+			}
+			stdin = str.Value
+		}
+		if val, ok := hashField(opts, "cwd"); ok {
+			str, ok := val.(*object.String)
+			if !ok {
+				return newTypeError("exec: cwd must be STRING, got %s", val.Type())
+			}
+			dir = str.Value
+		}
+		if val, ok := hashField(opts, "env"); ok {
+			vars, err := stringHash(val)
+			if err != nil {
+				return newTypeError("exec: env %s", err)
+			}
+			env = os.Environ()
+			for k, v := range vars {
+				env = append(env, k+"="+v)
+			}
+		}
+		if val, ok := hashField(opts, "timeout"); ok {
+			d, ok := val.(*object.Duration)
+			if !ok {
+				return newTypeError("exec: timeout must be DURATION, got %s", val.Type())
+			}
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, d.Value)
+			defer cancel()
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name.Value, cmdArgs...)
+	cmd.Stdin = strings.NewReader(stdin)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	cmd.Dir, cmd.Env = dir, env
+
+	err := cmd.Run()
+	if ctx.Err() != nil {
+		return newErrorOfKind(object.IO_ERROR, "exec: %s: %s", name.Value, ctx.Err())
+	}
+	code := 0
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &exitErr):
+		code = exitErr.ExitCode()
+	case err != nil:
+		return newErrorOfKind(object.IO_ERROR, "exec: %s", err)
+	}
+	return newHash(map[string]object.Object{
+		"stdout": &object.String{Value: stdout.String()},
+		"stderr": &object.String{Value: stderr.String()},
+		"code":   &object.Integer{Value: int64(code)},
+	})
+}
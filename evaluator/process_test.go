@@ -0,0 +1,111 @@
+package evaluator
+
+import (
+	"bytes"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/pechorka/plang/object"
+)
+
+func TestProcessBuiltins(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+	t.Setenv("PLANG_TEST_VAR", "при")
+	dir := t.TempDir()
+	e := New(WithProcess([]string{"a", "b"}))
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`args()[1]`, "b"},
+		{`len(args())`, 2},
+		{`env("PLANG_TEST_VAR")`, "при"},
+		{`env("PLANG_TEST_MISSING_VAR")`, nil},
+		{`env(1)`, "argument to `env` must be STRING, got INTEGER"},
+		{`exec("sh", ["-c", "echo out; echo err >&2"])["stdout"]`, "out\n"},
+		{`exec("sh", ["-c", "echo out; echo err >&2"])["stderr"]`, "err\n"},
+		{`exec("sh", ["-c", "exit 3"])["code"]`, 3},
+		{`exec("sh", ["-c", "cat"], {"stdin": "input"})["stdout"]`, "input"},
+		{`exec("sh", ["-c", "echo $PLANG_TEST_VAR $X"], {"env": {"X": "y"}})["stdout"]`, "при y\n"},
+		{`exec("pwd", [], {"cwd": "DIR"})["stdout"]`, dir + "\n"},
+		{`exec("sh", ["-c", "sleep 5"], {"timeout": duration("50ms")})`, "exec: sh: context deadline exceeded"},
+		{`exec("plang-missing-command")`, `exec: exec: "plang-missing-command": executable file not found in $PATH`},
+		{`exec("sh", [1])`, "`exec` expects array of STRING, got INTEGER at index 0"},
+		{`exec("sh", [], {"stdin": 1})`, "exec: stdin must be STRING, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		input := strings.ReplaceAll(tt.input, "DIR", dir)
+		testValue(t, testEvalWith(e, input), tt.expected)
+	}
+}
+
+func TestExit(t *testing.T) {
+	e := New(WithProcess(nil))
+	tests := []struct {
+		input        string
+		expectedCode int64
+	}{
+		{`exit()`, 0},
+		{`exit(3); 1`, 3},
+		{`let f = fn() { exit(4) }; f(); 1`, 4},
+		{`try { exit(5) } catch (e) { 1 }`, 5},
+		{`for (x in [1, 2]) { if (x == 2) { exit(x) } }`, 2},
+	}
+
+	for _, tt := range tests {
+		obj := testEvalWith(e, tt.input)
+		errObj, ok := obj.(*object.Error)
+		if !ok || errObj.Kind != object.EXIT {
+			t.Errorf("%q: expected exit, got %T (%+v)", tt.input, obj, obj)
+			continue
+		}
+		testIntegerObject(t, errObj.Value, tt.expectedCode)
+		if code, ok := errObj.ExitCode(); !ok || int64(code) != tt.expectedCode {
+			t.Errorf("%q: wrong exit code. expected=%d, got=%d, %v", tt.input, tt.expectedCode, code, ok)
+		}
+	}
+
+	// thrown hash with Exit kind is ordinary error, that can be caught
+	fake := `throw {"kind": "Exit", "message": "x"}`
+	obj := testEvalWith(New(), fake)
+	if errObj, ok := obj.(*object.Error); !ok || errObj.Kind != object.THROWN_ERROR {
+		t.Errorf("%q: expected thrown error, got %T (%+v)", fake, obj, obj)
+	} else if _, ok := errObj.ExitCode(); ok {
+		t.Errorf("%q: thrown error is exit", fake)
+	}
+	testValue(t, testEvalWith(New(), `try { `+fake+` } catch (e) { "caught" }`), "caught")
+
+	testValue(t, testEvalWith(e, `exit(256)`), "exit code must be between 0 and 255, got 256")
+
+	var out bytes.Buffer
+	e = New(WithProcess(nil), WithOutput(&out))
+	obj = testEvalWith(e, `try { exit(1) } finally { print("finally") }`)
+	if errObj, ok := obj.(*object.Error); !ok || errObj.Kind != object.EXIT {
+		t.Errorf("expected exit, got %T (%+v)", obj, obj)
+	}
+	if out.String() != "finally" {
+		t.Errorf("finally block didn't run. output=%q", out.String())
+	}
+}
+
+func TestProcessDisabledByDefault(t *testing.T) {
+	for name, b := range nameBuiltins(processBuiltins(nil)) {
+		obj := testEval(testCall(b))
+		errObj, ok := obj.(*object.Error)
+		if !ok {
+			t.Fatalf("%s: expected error, got %T (%+v)", name, obj, obj)
+		}
+		if errObj.Kind != object.PERMISSION_ERROR {
+			t.Errorf("%s: wrong error kind. expected=%q, got=%q", name, object.PERMISSION_ERROR, errObj.Kind)
+		}
+		expected := name + ": process access is not enabled"
+		if errObj.Message != expected {
+			t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+		}
+	}
+}
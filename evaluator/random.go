@@ -0,0 +1,177 @@
+package evaluator
+
+import (
+	crand "crypto/rand"
+	"math"
+	"math/rand"
+	"sync"
+
+	"github.com/pechorka/plang/object"
+)
+
+func init() {
+	registerBuiltins(randomBuiltins)
+}
+
+// WithSeed makes random builtins of the evaluator reproducible:
+// evaluators with the same seed produce the same values.
+// Without it the seed is taken from the current time.
+func WithSeed(seed int64) Option {
+	return func(e *Evaluator) {
+		e.rand = newRand(seed)
+	}
+}
+
+// Random builtins use the source of the interpreter, except for secure_random_bytes,
+// that reads from the cryptographically secure generator of the OS and ignores the seed.
+var randomBuiltins = map[string]*object.Builtin{
+	"random": {
+		Params: []string{},
+		Doc:    "Returns random float in [0, 1).",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			return &object.Float{Value: in.Rand().Float64()}
+		},
+	},
+	"rand_int": {
+		Params: []string{"lo", "hi"},
+		Doc:    "Returns random integer in [lo, hi].",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			lo, ok := args[0].(*object.Integer)
+			if !ok {
+				return newTypeError("first argument to `rand_int` must be INTEGER, got %s", args[0].Type())
+			}
+			hi, ok := args[1].(*object.Integer)
+			if !ok {
+				return newTypeError("second argument to `rand_int` must be INTEGER, got %s", args[1].Type())
+			}
+			if lo.Value > hi.Value {
+				return newArgumentError("rand_int: empty range [%d, %d]", lo.Value, hi.Value)
+			}
+			return &object.Integer{Value: randInt(in.Rand(), lo.Value, hi.Value)}
+		},
+	},
+	"choice": {
+		Params: []string{"iterable"},
+		Doc:    "Returns random value of the iterable.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			elements, err := iterableElements(args[0])
+			if err != nil {
+				return err
+			}
+			if len(elements) == 0 {
+				return newArgumentError("`choice` from empty sequence")
+			}
+			return elements[in.Rand().Intn(len(elements))]
+		},
+	},
+	"shuffle": {
+		Params: []string{"iterable"},
+		Doc:    "Returns array of values of the iterable in random order.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			elements, err := iterableElements(args[0])
+			if err != nil {
+				return err
+			}
+			in.Rand().Shuffle(len(elements), func(i, j int) {
+				elements[i], elements[j] = elements[j], elements[i]
+			})
+			return &object.Array{Elements: elements}
+		},
+	},
+	"sample": {
+		Params: []string{"iterable", "k"},
+		Doc:    "Returns array of k values of the iterable at distinct random positions.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			elements, err := iterableElements(args[0])
+			if err != nil {
+				return err
+			}
+			k, ok := args[1].(*object.Integer)
+			if !ok {
+				return newTypeError("second argument to `sample` must be INTEGER, got %s", args[1].Type())
+			}
+			if k.Value < 0 || k.Value > int64(len(elements)) {
+				return newArgumentError("sample: size must be between 0 and %d, got %d", len(elements), k.Value)
+			}
+			// partial Fisher-Yates: the first k elements are the sample
+			r := in.Rand()
+			for i := 0; i < int(k.Value); i++ {
+				j := i + r.Intn(len(elements)-i)
+				elements[i], elements[j] = elements[j], elements[i]
+			}
+			return &object.Array{Elements: elements[:k.Value]}
+		},
+	},
+	"secure_random_bytes": {
+		Params: []string{"n"},
+		Doc:    "Returns array of n bytes from the cryptographically secure generator.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			n, ok := args[0].(*object.Integer)
+			if !ok {
+				return newTypeError("argument to `secure_random_bytes` must be INTEGER, got %s", args[0].Type())
+			}
+			if n.Value < 0 || n.Value > maxSecureRandomBytes {
+				return newArgumentError("secure_random_bytes: size must be between 0 and %d, got %d",
+					maxSecureRandomBytes, n.Value)
+			}
+			buf := make([]byte, n.Value)
+			if _, err := crand.Read(buf); err != nil {
+				return newErrorOfKind(object.IO_ERROR, "secure_random_bytes: %s", err)
+			}
+			elements := make([]object.Object, len(buf))
+			for i, b := range buf {
+				elements[i] = &object.Integer{Value: int64(b)}
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+}
+
+// maxSecureRandomBytes limits the size, because the result is an array of integers,
+// that takes much more memory than the bytes themselves.
+const maxSecureRandomBytes = 1 << 20
+
+// randInt returns uniformly distributed integer in [lo, hi].
+func randInt(r *rand.Rand, lo, hi int64) int64 {
+	n := uint64(hi-lo) + 1 // wraps to 0 for the whole int64 range
+	switch {
+	case n == 0:
+		return int64(r.Uint64())
+	case n <= math.MaxInt64:
+		return lo + r.Int63n(int64(n))
+	}
+	// more than half of uint64 values are in range, so rejection is cheap
+	for {
+		if v := r.Uint64(); v < n {
+			return lo + int64(v)
+		}
+	}
+}
+
+func newRand(seed int64) *rand.Rand {
+	return rand.New(&lockedSource{src: rand.NewSource(seed).(rand.Source64)})
+}
+
+// lockedSource makes rand.Rand safe to use from spawned tasks.
+type lockedSource struct {
+	mu  sync.Mutex
+	src rand.Source64
+}
+
+func (s *lockedSource) Int63() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.src.Int63()
+}
+
+func (s *lockedSource) Uint64() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.src.Uint64()
+}
+
+func (s *lockedSource) Seed(seed int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.src.Seed(seed)
+}
@@ -0,0 +1,89 @@
+package evaluator
+
+import (
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestRandomBuiltins(t *testing.T) {
+	hundred := intsLiteral(100)
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let x = random(); if (x < 0) { false } else { x < 1 }`, true},
+		{`let x = rand_int(1, 3); if (x < 1) { false } else { x < 4 }`, true},
+		{`rand_int(5, 5)`, 5},
+		{`rand_int(3, 1)`, "rand_int: empty range [3, 1]"},
+		{`rand_int(1, "a")`, "second argument to `rand_int` must be INTEGER, got STRING"},
+		{`choice([7])`, 7},
+		{`contains("ab", choice(["a", "b"]))`, true},
+		{`choice([])`, "`choice` from empty sequence"},
+		{`choice(1)`, "INTEGER is not iterable"},
+		{`sort(shuffle([3, 1, 2]))`, []int64{1, 2, 3}},
+		{`let a = [1, 2, 3]; shuffle(a); a`, []int64{1, 2, 3}},
+		{`shuffle([])`, []int64{}},
+		{`sample([1, 2, 3], 0)`, []int64{}},
+		{`sort(sample([1, 2, 3], 3))`, []int64{1, 2, 3}},
+		{`len(unique(sample(` + hundred + `, 50)))`, 50},
+		{`sample([1, 2, 3], 4)`, "sample: size must be between 0 and 3, got 4"},
+		{`sample([1, 2, 3], -1)`, "sample: size must be between 0 and 3, got -1"},
+		{`len(secure_random_bytes(16))`, 16},
+		{`len(filter(secure_random_bytes(64), fn(b) { if (b < 0) { true } else { b > 255 } }))`, 0},
+		{`secure_random_bytes(-1)`, "secure_random_bytes: size must be between 0 and 1048576, got -1"},
+	}
+
+	for _, tt := range tests {
+		testValue(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestRandomIsReproducibleWithSeed(t *testing.T) {
+	hundred := intsLiteral(100)
+	input := `[random(), rand_int(0, 1000000), choice(` + hundred + `), shuffle(` + hundred + `), sample(` + hundred + `, 5)]`
+	first := testEvalWith(New(WithSeed(42)), input).Inspect()
+	second := testEvalWith(New(WithSeed(42)), input).Inspect()
+	if first != second {
+		t.Errorf("same seed produced different values: %s and %s", first, second)
+	}
+	other := testEvalWith(New(WithSeed(43)), input).Inspect()
+	if first == other {
+		t.Errorf("different seeds produced the same values: %s", first)
+	}
+}
+
+func TestRandomInSpawnedTasks(t *testing.T) {
+	input := `let roll = fn() { rand_int(1, 6) };
+len(filter(wait([spawn roll(), spawn roll(), spawn roll(), spawn roll()]), fn(x) { x < 7 }))`
+	testValue(t, testEvalWith(New(WithSeed(1)), input), 4)
+}
+
+func TestRandInt(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	ranges := [][2]int64{
+		{math.MinInt64, math.MaxInt64},
+		{-1, math.MaxInt64},
+		{math.MinInt64, 0},
+		{-3, 3},
+	}
+	for _, rng := range ranges {
+		for i := 0; i < 100; i++ {
+			v := randInt(r, rng[0], rng[1])
+			if v < rng[0] || v > rng[1] {
+				t.Fatalf("randInt(%d, %d) = %d, out of range", rng[0], rng[1], v)
+			}
+		}
+	}
+}
+
+// intsLiteral returns array literal [0, 1, ..., n-1].
+func intsLiteral(n int) string {
+	parts := make([]string, n)
+	for i := range parts {
+		parts[i] = strconv.Itoa(i)
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
@@ -0,0 +1,144 @@
+package evaluator
+
+import (
+	"regexp"
+
+	"github.com/pechorka/plang/object"
+)
+
+func init() {
+	registerBuiltins(regexBuiltins)
+}
+
+// Functions, that take a regex, also accept a pattern string, which is compiled on every call.
+// Strings have no escape sequences, so patterns are written as is: regex("\d+").
+var regexBuiltins = map[string]*object.Builtin{
+	"regex": {
+		Params: []string{"pattern"},
+		Doc:    "Compiles the pattern in Go regexp syntax.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			pattern, ok := args[0].(*object.String)
+			if !ok {
+				return newTypeError("argument to `regex` must be STRING, got %s", args[0].Type())
+			}
+			return compileRegex("regex", pattern.Value)
+		},
+	},
+	"match": {
+		Params: []string{"re", "str"},
+		Doc:    "Reports whether the string contains a match of the regex or pattern string.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			re, str, err := regexAndString("match", args)
+			if err != nil {
+				return err
+			}
+			return boolToBooleanObject(re.MatchString(str))
+		},
+	},
+	"find_all": {
+		Params: []string{"re", "str"},
+		Doc:    "Returns array of all matches of the regex or pattern string.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			re, str, err := regexAndString("find_all", args)
+			if err != nil {
+				return err
+			}
+			return stringsToArray(re.FindAllString(str, -1))
+		},
+	},
+	"captures": {
+		Params: []string{"re", "str"},
+		Doc:    "Returns hash of named groups of the first match or null, if there is no match.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			re, str, err := regexAndString("captures", args)
+			if err != nil {
+				return err
+			}
+			return captures(re, str)
+		},
+	},
+}
+
+// captures returns hash of named groups of the first match, or null if there is no match.
+// Group, that did not participate in the match, is null.
+func captures(re *regexp.Regexp, str string) object.Object {
+	loc := re.FindStringSubmatchIndex(str)
+	if loc == nil {
+		return NULL
+	}
+	pairs := make(map[object.HashKey]object.HashPair)
+	for i, name := range re.SubexpNames() {
+		if name == "" {
+			continue
+		}
+		key := &object.String{Value: name}
+		var value object.Object = NULL
+		if start, end := loc[2*i], loc[2*i+1]; start >= 0 {
+			value = &object.String{Value: str[start:end]}
+		}
+		pairs[key.HashKey()] = object.HashPair{Key: key, Value: value}
+	}
+	return &object.Hash{Pairs: pairs}
+}
+
+// replaceRegex replaces all matches of re in str.
+// String replacement may refer to groups with $1 or ${name}.
+// Function replacement is called with the matched string and must return a string.
+func replaceRegex(in object.Interpreter, str string, re *regexp.Regexp, replacement object.Object) object.Object {
+	switch replacement := replacement.(type) {
+	case *object.String:
+		return &object.String{Value: re.ReplaceAllString(str, replacement.Value)}
+	case *object.Function, *object.Builtin:
+		var err object.Object
+		result := re.ReplaceAllStringFunc(str, func(match string) string {
+			if err != nil {
+				return match
+			}
+			res := in.Apply(replacement, &object.String{Value: match})
+			if isError(res) {
+				err = res
+				return match
+			}
+			s, ok := res.(*object.String)
+			if !ok {
+				err = newTypeError("replacement function of `replace` must return STRING, got %s", res.Type())
+				return match
+			}
+			return s.Value
+		})
+		if err != nil {
+			return err
+		}
+		return &object.String{Value: result}
+	}
+	return newTypeError("third argument to `replace` must be STRING or FUNCTION, got %s", replacement.Type())
+}
+
+func regexAndString(name string, args []object.Object) (*regexp.Regexp, string, *object.Error) {
+	var re *regexp.Regexp
+	switch pattern := args[0].(type) {
+	case *object.Regex:
+		re = pattern.Value
+	case *object.String:
+		compiled := compileRegex(name, pattern.Value)
+		if err, ok := compiled.(*object.Error); ok {
+			return nil, "", err
+		}
+		re = compiled.(*object.Regex).Value
+	default:
+		return nil, "", newTypeError("first argument to `%s` must be REGEX or STRING, got %s", name, args[0].Type())
+	}
+	str, ok := args[1].(*object.String)
+	if !ok {
+		return nil, "", newTypeError("second argument to `%s` must be STRING, got %s", name, args[1].Type())
+	}
+	return re, str.Value, nil
+}
+
+func compileRegex(name, pattern string) object.Object {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return newArgumentError("%s: invalid pattern: %s", name, err)
+	}
+	return &object.Regex{Value: re}
+}
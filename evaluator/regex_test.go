@@ -0,0 +1,46 @@
+package evaluator
+
+import "testing"
+
+func TestRegexBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`type(regex("a+"))`, "REGEX"},
+		{`regex("(")`, "regex: invalid pattern: error parsing regexp: missing closing ): `(`"},
+		{`regex(1)`, "argument to `regex` must be STRING, got INTEGER"},
+		{`match(regex("^\d+$"), "123")`, true},
+		{`match("^\d+$", "12a")`, false},
+		{`match("[", "a")`, "match: invalid pattern: error parsing regexp: missing closing ]: `[`"},
+		{`match(1, "a")`, "first argument to `match` must be REGEX or STRING, got INTEGER"},
+		{`len(find_all(regex("\d+"), "a1 b22 c333"))`, 3},
+		{`find_all(regex("\d+"), "a1 b22 c333")[2]`, "333"},
+		{`len(find_all("x", "abc"))`, 0},
+		{`captures(regex("(?P<key>\w+)=(?P<value>\w+)"), "a=1")["value"]`, "1"},
+		{`captures(regex("(?P<key>\w+)=(?P<value>\w+)"), "no match")`, nil},
+		{`captures(regex("(?P<a>x)|(?P<b>y)"), "y")["a"]`, nil},
+		{`captures(regex("(?P<a>x)|(?P<b>y)"), "y")["b"]`, "y"},
+		{`type(captures(regex("(\d)"), "1"))`, "HASH"},
+		{`join(split("a1b22c", regex("\d+")), ",")`, "a,b,c"},
+		{`replace("a1b22", regex("\d+"), "#")`, "a#b#"},
+		{`replace("k=v", regex("(\w)=(\w)"), "$2=$1")`, "v=k"},
+		{`replace("a1b22", regex("\d+"), fn(m) { repeat("x", len(m)) })`, "axbxx"},
+		{`replace("a.b.c", ".", fn(m) { "-" })`, "a-b-c"},
+		{`replace("a.b", ".", "$")`, "a$b"},
+		{`replace("a1", regex("\d"), fn(m) { 1 })`, "replacement function of `replace` must return STRING, got INTEGER"},
+		{`replace("a1", regex("\d"), fn(m) { throw "boom" })`, "boom"},
+		{`replace("a1", 1, "x")`, "second argument to `replace` must be STRING or REGEX, got INTEGER"},
+		{`replace("a1", regex("\d"), 1)`, "third argument to `replace` must be STRING or FUNCTION, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		testValue(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestRegexInspect(t *testing.T) {
+	if got := testEval(`regex("\d+")`).Inspect(); got != `regex("\\d+")` {
+		t.Errorf("wrong inspect. got=%s", got)
+	}
+}
@@ -0,0 +1,223 @@
+package evaluator
+
+import (
+	"sort"
+
+	"github.com/pechorka/plang/object"
+)
+
+func init() {
+	registerBuiltins(sortBuiltins)
+}
+
+// Sorting functions accept any iterable and return a new array.
+// Natural ordering is defined by compareObjects: numbers, strings, booleans,
+// times and durations are comparable with values of the same kind.
+var sortBuiltins = map[string]*object.Builtin{
+	"sort": {
+		Params: []string{"iterable", "cmp?"},
+		Doc:    "Returns array of sorted values. Comparator cmp(a, b) returns negative, zero or positive integer. The sort is stable.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			elements, err := iterableElements(args[0])
+			if err != nil {
+				return err
+			}
+			var less func(a, b object.Object) (bool, object.Object)
+			if len(args) == 1 {
+				less = naturalLess("sort")
+			} else {
+				less = comparatorLess(in, args[1])
+			}
+			if err := stableSort(elements, less); err != nil {
+				return err
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+	"sort_by": {
+		Params: []string{"iterable", "key"},
+		Doc:    "Returns array of values sorted by results of key. The sort is stable.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			elements, err := iterableElements(args[0])
+			if err != nil {
+				return err
+			}
+			// pairs of key and value, so key is computed once for every value
+			pairs := make([]object.Object, len(elements))
+			for i, val := range elements {
+				key := in.Apply(args[1], val)
+				if isError(key) {
+					return key
+				}
+				pairs[i] = &object.Array{Elements: []object.Object{key, val}}
+			}
+			less := naturalLess("sort_by")
+			byKey := func(a, b object.Object) (bool, object.Object) {
+				return less(a.(*object.Array).Elements[0], b.(*object.Array).Elements[0])
+			}
+			if err := stableSort(pairs, byKey); err != nil {
+				return err
+			}
+			for i, pair := range pairs {
+				elements[i] = pair.(*object.Array).Elements[1]
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+	"reverse": {
+		Params: []string{"value"},
+		Doc:    "Returns reversed string or array of values of the iterable in reverse order.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			if str, ok := args[0].(*object.String); ok {
+				runes := []rune(str.Value)
+				for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+					runes[i], runes[j] = runes[j], runes[i]
+				}
+				return &object.String{Value: string(runes)}
+			}
+			elements, err := iterableElements(args[0])
+			if err != nil {
+				return err
+			}
+			for i, j := 0, len(elements)-1; i < j; i, j = i+1, j-1 {
+				elements[i], elements[j] = elements[j], elements[i]
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+	"unique": {
+		Params: []string{"iterable"},
+		Doc:    "Returns array of values without duplicates, keeping the first occurrences.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			elements, err := iterableElements(args[0])
+			if err != nil {
+				return err
+			}
+			seen := make(map[object.HashKey]bool, len(elements))
+			result := make([]object.Object, 0, len(elements))
+			for i, el := range elements {
+				hashable, ok := el.(object.Hashable)
+				if !ok {
+					return newTypeError("`unique` can't compare %s at index %d", el.Type(), i)
+				}
+				key := hashable.HashKey()
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+				result = append(result, el)
+			}
+			return &object.Array{Elements: result}
+		},
+	},
+	"min_by": {
+		Params: []string{"iterable", "key"},
+		Doc:    "Returns value with the smallest result of key or null for empty iterable.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			return extremumBy(in, "min_by", -1, args)
+		},
+	},
+	"max_by": {
+		Params: []string{"iterable", "key"},
+		Doc:    "Returns value with the greatest result of key or null for empty iterable.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			return extremumBy(in, "max_by", 1, args)
+		},
+	},
+}
+
+// stableSort sorts elements in place. The first error returned by less
+// stops further comparisons and is returned.
+func stableSort(elements []object.Object, less func(a, b object.Object) (bool, object.Object)) object.Object {
+	var err object.Object
+	sort.SliceStable(elements, func(i, j int) bool {
+		if err != nil {
+			return false
+		}
+		res, lessErr := less(elements[i], elements[j])
+		if lessErr != nil {
+			err = lessErr
+		}
+		return res
+	})
+	return err
+}
+
+func naturalLess(name string) func(a, b object.Object) (bool, object.Object) {
+	return func(a, b object.Object) (bool, object.Object) {
+		c, ok := compareObjects(a, b)
+		if !ok {
+			return false, newTypeError("`%s` can't compare %s with %s", name, a.Type(), b.Type())
+		}
+		return c < 0, nil
+	}
+}
+
+// comparatorLess uses plang function cmp(a, b), that returns
+// negative, zero or positive integer, if a is less, equal or greater than b.
+func comparatorLess(in object.Interpreter, cmp object.Object) func(a, b object.Object) (bool, object.Object) {
+	return func(a, b object.Object) (bool, object.Object) {
+		res := in.Apply(cmp, a, b)
+		if isError(res) {
+			return false, res
+		}
+		c, ok := res.(*object.Integer)
+		if !ok {
+			return false, newTypeError("comparator of `sort` must return INTEGER, got %s", res.Type())
+		}
+		return c.Value < 0, nil
+	}
+}
+
+// extremumBy returns element with the smallest (sign -1) or the greatest (sign 1) key.
+// The first of equal elements wins. Empty iterable results in null.
+func extremumBy(in object.Interpreter, name string, sign int, args []object.Object) object.Object {
+	it, err := iterate(args[0])
+	if err != nil {
+		return err
+	}
+	var best, bestKey object.Object
+	res := forEach(it, func(val object.Object) object.Object {
+		key := in.Apply(args[1], val)
+		if isError(key) {
+			return key
+		}
+		if best == nil {
+			best, bestKey = val, key
+			return nil
+		}
+		c, ok := compareObjects(key, bestKey)
+		if !ok {
+			return newTypeError("`%s` can't compare %s with %s", name, key.Type(), bestKey.Type())
+		}
+		if c == sign {
+			best, bestKey = val, key
+		}
+		return nil
+	})
+	if res != nil {
+		return res
+	}
+	if best == nil {
+		return NULL
+	}
+	return best
+}
+
+// iterableElements collects elements of iterable into a new slice,
+// that can be modified without affecting the argument.
+func iterableElements(obj object.Object) ([]object.Object, object.Object) {
+	it, err := iterate(obj)
+	if err != nil {
+		return nil, err
+	}
+	elements := []object.Object{}
+	res := forEach(it, func(val object.Object) object.Object {
+		elements = append(elements, val)
+		return nil
+	})
+	if res != nil {
+		return nil, res
+	}
+	return elements, nil
+}
@@ -0,0 +1,48 @@
+package evaluator
+
+import "testing"
+
+func TestSortBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`sort([3, 1, 2])`, []int64{1, 2, 3}},
+		{`sort([])`, []int64{}},
+		{`sort([2.5, 1, 2])[0]`, 1},
+		{`sort([2.5, 1, 2])[2]`, 2.5},
+		{`join(sort(["b", "a", "в", "c"]), "")`, "abcв"},
+		{`sort([true, false])[0]`, false},
+		{`sort([duration("1s"), duration("1ms")])[0] == duration("1ms")`, true},
+		{`sort([1, "a"])`, "`sort` can't compare STRING with INTEGER"},
+		{`sort([[1], [2]])`, "`sort` can't compare ARRAY with ARRAY"},
+		{`sort(1)`, "INTEGER is not iterable"},
+		{`sort([3, 1, 2], fn(a, b) { b - a })`, []int64{3, 2, 1}},
+		{`sort([1, 2], fn(a, b) { true })`, "comparator of `sort` must return INTEGER, got BOOLEAN"},
+		{`sort([1, 2], fn(a, b) { throw "cmp failed" })`, "cmp failed"},
+		{`sort([1, 2], fn(a) { 0 })`, "wrong number of arguments. got=2, want=1"},
+		{`reverse([1, 2, 3])`, []int64{3, 2, 1}},
+		{`reverse([])`, []int64{}},
+		{`reverse("привет")`, "тевирп"},
+		{`let a = [1, 2]; reverse(a); a`, []int64{1, 2}},
+		{`unique([1, 2, 1, 3, 2])`, []int64{1, 2, 3}},
+		{`join(unique(["a", "b", "a"]), "")`, "ab"},
+		{`unique([1, 1.0, 0.0, -0.0, 2.5, 2])`, []interface{}{1, 0.0, 2.5, 2}},
+		{`unique([1, [1]])`, "`unique` can't compare ARRAY at index 1"},
+		{`min_by(["ccc", "a", "bb"], len)`, "a"},
+		{`max_by(["ccc", "a", "bbb"], len)`, "ccc"},
+		{`min_by([], len)`, nil},
+		{`max_by([1, "a"], fn(x) { x })`, "`max_by` can't compare STRING with INTEGER"},
+	}
+
+	for _, tt := range tests {
+		testValue(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestSortIsStable(t *testing.T) {
+	input := `let pairs = [[1, "a"], [0, "b"], [1, "c"], [0, "d"]];
+let sorted = sort(pairs, fn(x, y) { x[0] - y[0] });
+join(map(sorted, fn(p) { p[1] }), "")`
+	testValue(t, testEval(input), "bdac")
+}
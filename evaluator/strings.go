@@ -0,0 +1,282 @@
+package evaluator
+
+import (
+	"math"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pechorka/plang/object"
+)
+
+func init() {
+	registerBuiltins(stringBuiltins)
+}
+
+// All positions and lengths are counted in runes, not in bytes.
+var stringBuiltins = map[string]*object.Builtin{
+	"split": {
+		Params: []string{"str", "sep"},
+		Doc:    "Splits the string around the separator, which is a string or a regex.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			if re, ok := args[1].(*object.Regex); ok {
+				str, ok := args[0].(*object.String)
+				if !ok {
+					return newTypeError("first argument to `split` must be STRING, got %s", args[0].Type())
+				}
+				return stringsToArray(re.Value.Split(str.Value, -1))
+			}
+			str, sep, err := twoStringArgs("split", args)
+			if err != nil {
+				return err
+			}
+			return stringsToArray(strings.Split(str, sep))
+		},
+	},
+	"join": {
+		Params: []string{"arr", "sep"},
+		Doc:    "Joins strings of the array with the separator.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			arr, ok := args[0].(*object.Array)
+			if !ok {
+				return newTypeError("first argument to `join` must be ARRAY, got %s", args[0].Type())
+			}
+			sep, ok := args[1].(*object.String)
+			if !ok {
+				return newTypeError("second argument to `join` must be STRING, got %s", args[1].Type())
+			}
+			parts := make([]string, len(arr.Elements))
+			for i, el := range arr.Elements {
+				str, ok := el.(*object.String)
+				if !ok {
+					return newTypeError("`join` expects array of STRING, got %s at index %d", el.Type(), i)
+				}
+				parts[i] = str.Value
+			}
+			return &object.String{Value: strings.Join(parts, sep.Value)}
+		},
+	},
+	"trim": {
+		Params: []string{"str", "cutset?"},
+		Doc:    "Removes leading and trailing whitespace or characters of the cutset.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			str, ok := args[0].(*object.String)
+			if !ok {
+				return newTypeError("first argument to `trim` must be STRING, got %s", args[0].Type())
+			}
+			if len(args) == 1 {
+				return &object.String{Value: strings.TrimSpace(str.Value)}
+			}
+			cutset, ok := args[1].(*object.String)
+			if !ok {
+				return newTypeError("second argument to `trim` must be STRING, got %s", args[1].Type())
+			}
+			return &object.String{Value: strings.Trim(str.Value, cutset.Value)}
+		},
+	},
+	"contains": {
+		Params: []string{"str", "substr"},
+		Doc:    "Reports whether the substring is in the string.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			str, sub, err := twoStringArgs("contains", args)
+			if err != nil {
+				return err
+			}
+			return boolToBooleanObject(strings.Contains(str, sub))
+		},
+	},
+	"starts_with": {
+		Params: []string{"str", "prefix"},
+		Doc:    "Reports whether the string begins with the prefix.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			str, prefix, err := twoStringArgs("starts_with", args)
+			if err != nil {
+				return err
+			}
+			return boolToBooleanObject(strings.HasPrefix(str, prefix))
+		},
+	},
+	"ends_with": {
+		Params: []string{"str", "suffix"},
+		Doc:    "Reports whether the string ends with the suffix.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			str, suffix, err := twoStringArgs("ends_with", args)
+			if err != nil {
+				return err
+			}
+			return boolToBooleanObject(strings.HasSuffix(str, suffix))
+		},
+	},
+	"replace": {
+		Params: []string{"str", "pattern", "replacement"},
+		Doc:    "Replaces all occurrences of the pattern, which is a string or a regex. Replacement is a string or a function of the match.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			str, ok := args[0].(*object.String)
+			if !ok {
+				return newTypeError("first argument to `replace` must be STRING, got %s", args[0].Type())
+			}
+			var re *regexp.Regexp
+			switch pattern := args[1].(type) {
+			case *object.String:
+				if replacement, ok := args[2].(*object.String); ok {
+					return &object.String{Value: strings.ReplaceAll(str.Value, pattern.Value, replacement.Value)}
+				}
+				re = regexp.MustCompile(regexp.QuoteMeta(pattern.Value))
+			case *object.Regex:
+				re = pattern.Value
+			default:
+				return newTypeError("second argument to `replace` must be STRING or REGEX, got %s", args[1].Type())
+			}
+			return replaceRegex(in, str.Value, re, args[2])
+		},
+	},
+	"upper": {
+		Params: []string{"str"},
+		Doc:    "Returns the string in upper case.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			str, ok := args[0].(*object.String)
+			if !ok {
+				return newTypeError("argument to `upper` must be STRING, got %s", args[0].Type())
+			}
+			return &object.String{Value: strings.ToUpper(str.Value)}
+		},
+	},
+	"lower": {
+		Params: []string{"str"},
+		Doc:    "Returns the string in lower case.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			str, ok := args[0].(*object.String)
+			if !ok {
+				return newTypeError("argument to `lower` must be STRING, got %s", args[0].Type())
+			}
+			return &object.String{Value: strings.ToLower(str.Value)}
+		},
+	},
+	"index_of": {
+		Params: []string{"str", "substr"},
+		Doc:    "Returns index of the first occurrence of the substring in characters or -1.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			str, sub, err := twoStringArgs("index_of", args)
+			if err != nil {
+				return err
+			}
+			idx := strings.Index(str, sub)
+			if idx < 0 {
+				return &object.Integer{Value: -1}
+			}
+			return &object.Integer{Value: int64(utf8.RuneCountInString(str[:idx]))}
+		},
+	},
+	"repeat": {
+		Params: []string{"str", "count"},
+		Doc:    "Returns the string repeated count times.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			str, ok := args[0].(*object.String)
+			if !ok {
+				return newTypeError("first argument to `repeat` must be STRING, got %s", args[0].Type())
+			}
+			count, ok := args[1].(*object.Integer)
+			if !ok {
+				return newTypeError("second argument to `repeat` must be INTEGER, got %s", args[1].Type())
+			}
+			return repeatString(str, count)
+		},
+	},
+	"pad_left": {
+		Params: []string{"str", "width", "pad?"},
+		Doc:    "Pads the string on the left to the width with spaces or the pad string.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			return pad("pad_left", args, func(str, padding string) string {
+				return padding + str
+			})
+		},
+	},
+	"pad_right": {
+		Params: []string{"str", "width", "pad?"},
+		Doc:    "Pads the string on the right to the width with spaces or the pad string.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			return pad("pad_right", args, func(str, padding string) string {
+				return str + padding
+			})
+		},
+	},
+}
+
+func twoStringArgs(name string, args []object.Object) (string, string, *object.Error) {
+	first, ok := args[0].(*object.String)
+	if !ok {
+		return "", "", newTypeError("first argument to `%s` must be STRING, got %s", name, args[0].Type())
+	}
+	second, ok := args[1].(*object.String)
+	if !ok {
+		return "", "", newTypeError("second argument to `%s` must be STRING, got %s", name, args[1].Type())
+	}
+	return first.Value, second.Value, nil
+}
+
+func stringsToArray(strs []string) *object.Array {
+	elements := make([]object.Object, len(strs))
+	for i, s := range strs {
+		elements[i] = &object.String{Value: s}
+	}
+	return &object.Array{Elements: elements}
+}
+
+func repeatString(str *object.String, count *object.Integer) object.Object {
+	if count.Value < 0 {
+		return newArgumentError("negative repeat count: %d", count.Value)
+	}
+	repeated, err := repeat(str.Value, count.Value, "repeated")
+	if err != nil {
+		return err
+	}
+	return &object.String{Value: repeated}
+}
+
+// repeat repeats the string count times, unless the result is longer than math.MaxInt32 bytes.
+// what describes the result in the error, e.g. "padded".
+func repeat(str string, count int64, what string) (string, *object.Error) {
+	if count > 0 && int64(len(str)) > math.MaxInt32/count {
+		return "", newArgumentError("%s string is too long", what)
+	}
+	return strings.Repeat(str, int(count)), nil
+}
+
+// pad pads string from args[0] to args[1] runes with args[2] or spaces.
+// Padding is built by repeating the pad string and cutting it to the needed length.
+func pad(name string, args []object.Object, join func(str, padding string) string) object.Object {
+	str, ok := args[0].(*object.String)
+	if !ok {
+		return newTypeError("first argument to `%s` must be STRING, got %s", name, args[0].Type())
+	}
+	width, ok := args[1].(*object.Integer)
+	if !ok {
+		return newTypeError("second argument to `%s` must be INTEGER, got %s", name, args[1].Type())
+	}
+	padWith := " "
+	if len(args) == 3 {
+		p, ok := args[2].(*object.String)
+		if !ok {
+			return newTypeError("third argument to `%s` must be STRING, got %s", name, args[2].Type())
+		}
+		if p.Value == "" {
+			return newArgumentError("pad string of `%s` must not be empty", name)
+		}
+		padWith = p.Value
+	}
+
+	missing := width.Value - int64(utf8.RuneCountInString(str.Value))
+	if missing <= 0 {
+		return str
+	}
+	padding, err := repeat(padWith, missing/int64(utf8.RuneCountInString(padWith))+1, "padded")
+	if err != nil {
+		return err
+	}
+	end := 0
+	for i := int64(0); i < missing; i++ {
+		_, size := utf8.DecodeRuneInString(padding[end:])
+		end += size
+	}
+	return &object.String{Value: join(str.Value, padding[:end])}
+}
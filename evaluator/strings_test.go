@@ -0,0 +1,66 @@
+package evaluator
+
+import "testing"
+
+func TestStringBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`split("a,b,c", ",")[2]`, "c"},
+		{`len(split("a,b,c", ","))`, 3},
+		{`split("при", "")[1]`, "р"},
+		{`split(1, ",")`, "first argument to `split` must be STRING, got INTEGER"},
+		{`join(["a", "b"], ", ")`, "a, b"},
+		{`join([], ",")`, ""},
+		{`join(["a", 1], ",")`, "`join` expects array of STRING, got INTEGER at index 1"},
+		{"trim(\"  hi \t\")", "hi"},
+		{`trim("--hi--", "-")`, "hi"},
+		{`contains("привет", "ив")`, true},
+		{`contains("привет", "x")`, false},
+		{`starts_with("привет", "пр")`, true},
+		{`ends_with("привет", "пр")`, false},
+		{`replace("a-b-c", "-", "+")`, "a+b+c"},
+		{`replace("a", "b")`, "wrong number of arguments. got=2, want=3"},
+		{`upper("привет")`, "ПРИВЕТ"},
+		{`lower("ÀB")`, "àb"},
+		{`index_of("привет", "вет")`, 3},
+		{`index_of("привет", "x")`, -1},
+		{`repeat("ab", 3)`, "ababab"},
+		{`repeat("ab", -1)`, "negative repeat count: -1"},
+		{`pad_left("7", 3, "0")`, "007"},
+		{`pad_left("при", 5)`, "  при"},
+		{`pad_right("при", 6, "ab")`, "приaba"},
+		{`pad_right("long", 2)`, "long"},
+		{`pad_left("a", 3, "")`, "pad string of `pad_left` must not be empty"},
+		{`pad_left("a", 100000000000)`, "padded string is too long"},
+		{`pad_right("a", 3000000000, "ab")`, "padded string is too long"},
+	}
+
+	for _, tt := range tests {
+		testValue(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestStringOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`"a" < "b"`, true},
+		{`"b" < "a"`, false},
+		{`"абв" > "абб"`, true},
+		{`"a" == "a"`, true},
+		{`"a" != "a"`, false},
+		{`"a" == "b"`, false},
+		{`"ab" * 2`, "abab"},
+		{`3 * "я"`, "яяя"},
+		{`"a" * 0`, ""},
+		{`"a" * -1`, "negative repeat count: -1"},
+		{`"a" / 2`, "type mismatch: STRING / INTEGER"},
+	}
+
+	for _, tt := range tests {
+		testValue(t, testEval(tt.input), tt.expected)
+	}
+}
@@ -0,0 +1,280 @@
+package evaluator
+
+import (
+	"math"
+	"time"
+	_ "time/tzdata" // in_zone must work on hosts without zoneinfo
+
+	"github.com/pechorka/plang/object"
+)
+
+func init() {
+	registerBuiltins(timeBuiltins)
+}
+
+// Layouts of format_time and parse_time are Go layouts, e.g. "2006-01-02 15:04:05".
+// Times without explicit location are in UTC.
+var timeBuiltins = map[string]*object.Builtin{
+	"now": {
+		Params: []string{},
+		Doc:    "Returns the current time.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			return &object.Time{Value: time.Now()}
+		},
+	},
+	"unix": {
+		Params: []string{"t?"},
+		Doc:    "Returns seconds since Unix epoch of the time or of the current time.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			if len(args) == 0 {
+				return &object.Integer{Value: time.Now().Unix()}
+			}
+			t, ok := args[0].(*object.Time)
+			if !ok {
+				return newTypeError("argument to `unix` must be TIME, got %s", args[0].Type())
+			}
+			return &object.Integer{Value: t.Value.Unix()}
+		},
+	},
+	"from_unix": {
+		Params: []string{"seconds"},
+		Doc:    "Returns time in UTC from seconds since Unix epoch.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			sec, ok := args[0].(*object.Integer)
+			if !ok {
+				return newTypeError("argument to `from_unix` must be INTEGER, got %s", args[0].Type())
+			}
+			return &object.Time{Value: time.Unix(sec.Value, 0).UTC()}
+		},
+	},
+	"format_time": {
+		Params: []string{"t", "layout"},
+		Doc:    "Formats the time according to Go layout, e.g. \"2006-01-02\".",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			t, ok := args[0].(*object.Time)
+			if !ok {
+				return newTypeError("first argument to `format_time` must be TIME, got %s", args[0].Type())
+			}
+			layout, ok := args[1].(*object.String)
+			if !ok {
+				return newTypeError("second argument to `format_time` must be STRING, got %s", args[1].Type())
+			}
+			return &object.String{Value: t.Value.Format(layout.Value)}
+		},
+	},
+	"parse_time": {
+		Params: []string{"str", "layout", "zone?"},
+		Doc:    "Parses time according to Go layout in the zone, which is UTC by default.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			str, layout, err := twoStringArgs("parse_time", args)
+			if err != nil {
+				return err
+			}
+			loc := time.UTC
+			if len(args) == 3 {
+				loc, err = locationArg("parse_time", args[2])
+				if err != nil {
+					return err
+				}
+			}
+			t, perr := time.ParseInLocation(layout, str, loc)
+			if perr != nil {
+				return newArgumentError("parse_time: %s", perr)
+			}
+			return &object.Time{Value: t}
+		},
+	},
+	"in_zone": {
+		Params: []string{"t", "zone"},
+		Doc:    "Returns the time in the zone, e.g. \"Europe/Moscow\".",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			t, ok := args[0].(*object.Time)
+			if !ok {
+				return newTypeError("first argument to `in_zone` must be TIME, got %s", args[0].Type())
+			}
+			loc, err := locationArg("in_zone", args[1])
+			if err != nil {
+				return err
+			}
+			return &object.Time{Value: t.Value.In(loc)}
+		},
+	},
+	"duration": {
+		Params: []string{"str"},
+		Doc:    "Parses duration, e.g. \"1h30m\".",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			str, ok := args[0].(*object.String)
+			if !ok {
+				return newTypeError("argument to `duration` must be STRING, got %s", args[0].Type())
+			}
+			d, err := time.ParseDuration(str.Value)
+			if err != nil {
+				return newArgumentError("duration: %s", err)
+			}
+			return &object.Duration{Value: d}
+		},
+	},
+	"seconds": {
+		Params: []string{"d"},
+		Doc:    "Returns the duration in seconds as a float.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			d, ok := args[0].(*object.Duration)
+			if !ok {
+				return newTypeError("argument to `seconds` must be DURATION, got %s", args[0].Type())
+			}
+			return &object.Float{Value: d.Value.Seconds()}
+		},
+	},
+	"milliseconds": {
+		Params: []string{"d"},
+		Doc:    "Returns the duration in whole milliseconds.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			d, ok := args[0].(*object.Duration)
+			if !ok {
+				return newTypeError("argument to `milliseconds` must be DURATION, got %s", args[0].Type())
+			}
+			return &object.Integer{Value: d.Value.Milliseconds()}
+		},
+	},
+	"sleep": {
+		Params: []string{"d"},
+		Doc:    "Pauses for the duration or the number of milliseconds.",
+		Fn: func(in object.Interpreter, args ...object.Object) object.Object {
+			switch d := args[0].(type) {
+			case *object.Duration:
+				time.Sleep(d.Value)
+			case *object.Integer: // milliseconds
+				if d.Value > math.MaxInt64/int64(time.Millisecond) || d.Value < math.MinInt64/int64(time.Millisecond) {
+					return newArgumentError("sleep: duration is too long")
+				}
+				time.Sleep(time.Duration(d.Value) * time.Millisecond)
+			default:
+				return newTypeError("argument to `sleep` must be DURATION or INTEGER, got %s", args[0].Type())
+			}
+			return NULL
+		},
+	},
+}
+
+func locationArg(name string, arg object.Object) (*time.Location, *object.Error) {
+	zone, ok := arg.(*object.String)
+	if !ok {
+		return nil, newTypeError("time zone argument to `%s` must be STRING, got %s", name, arg.Type())
+	}
+	loc, err := time.LoadLocation(zone.Value)
+	if err != nil {
+		return nil, newArgumentError("%s: unknown time zone %q", name, zone.Value)
+	}
+	return loc, nil
+}
+
+func isTemporal(obj object.Object) bool {
+	switch obj.(type) {
+	case *object.Time, *object.Duration:
+		return true
+	}
+	return false
+}
+
+// evalTimeInfixExpression handles operators, where at least one operand is time or duration:
+//
+//	time - time          -> duration
+//	time ± duration      -> time
+//	duration ± duration  -> duration
+//	duration * integer   -> duration
+//	duration / integer   -> duration
+//	duration / duration  -> float
+//
+// and comparison of times or durations with each other.
+func evalTimeInfixExpression(operator string, left, right object.Object) object.Object {
+	switch operator {
+	case "<", ">", "==", "!=":
+		cmp, ok := compareObjects(left, right)
+		if !ok {
+			if operator == "==" || operator == "!=" {
+				return boolToBooleanObject(operator == "!=")
+			}
+			return newTypeError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
+		}
+		switch operator {
+		case "<":
+			return boolToBooleanObject(cmp < 0)
+		case ">":
+			return boolToBooleanObject(cmp > 0)
+		case "==":
+			return boolToBooleanObject(cmp == 0)
+		}
+		return boolToBooleanObject(cmp != 0)
+	}
+
+	switch l := left.(type) {
+	case *object.Time:
+		switch r := right.(type) {
+		case *object.Time:
+			if operator == "-" {
+				return &object.Duration{Value: l.Value.Sub(r.Value)}
+			}
+		case *object.Duration:
+			switch operator {
+			case "+":
+				return &object.Time{Value: l.Value.Add(r.Value)}
+			case "-":
+				return &object.Time{Value: l.Value.Add(-r.Value)}
+			}
+		}
+	case *object.Duration:
+		switch r := right.(type) {
+		case *object.Time:
+			if operator == "+" {
+				return &object.Time{Value: r.Value.Add(l.Value)}
+			}
+		case *object.Duration:
+			switch operator {
+			case "+", "-":
+				return evalDurationArithmetic(operator, l.Value, r.Value)
+			case "/":
+				if r.Value == 0 {
+					return newError("division by zero")
+				}
+				return &object.Float{Value: float64(l.Value) / float64(r.Value)}
+			}
+		case *object.Integer:
+			switch operator {
+			case "*", "/":
+				return evalDurationArithmetic(operator, l.Value, time.Duration(r.Value))
+			}
+		}
+	case *object.Integer:
+		if r, ok := right.(*object.Duration); ok && operator == "*" {
+			return evalDurationArithmetic(operator, r.Value, time.Duration(l.Value))
+		}
+	}
+	return newTypeError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+}
+
+// evalDurationArithmetic applies operator to durations.
+// For * and / right is a plain number.
+func evalDurationArithmetic(operator string, left, right time.Duration) object.Object {
+	var (
+		res int64
+		ok  = true
+	)
+	switch operator {
+	case "+":
+		res, ok = addInt(int64(left), int64(right))
+	case "-":
+		res = int64(left - right)
+		ok = (right >= 0) == (left-right <= left)
+	case "*":
+		res, ok = mulInt(int64(left), int64(right))
+	case "/":
+		if right == 0 {
+			return newError("division by zero")
+		}
+		res = int64(left / right)
+	}
+	if !ok {
+		return newError("duration overflow")
+	}
+	return &object.Duration{Value: time.Duration(res)}
+}
@@ -0,0 +1,76 @@
+package evaluator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pechorka/plang/object"
+)
+
+func TestTimeBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`type(now())`, "TIME"},
+		{`unix() > 1600000000`, true},
+		{`unix(from_unix(1700000000))`, 1700000000},
+		{`format_time(from_unix(0), "2006-01-02 15:04")`, "1970-01-01 00:00"},
+		{`let t = parse_time("2024-02-29 13:45", "2006-01-02 15:04"); format_time(t, "Jan 2, 2006 at 3:04pm (MST)")`, "Feb 29, 2024 at 1:45pm (UTC)"},
+		{`unix(parse_time("2024-01-01 03:00", "2006-01-02 15:04", "Europe/Moscow"))`, 1704067200},
+		{`parse_time("2024-13-01", "2006-01-02")`, `parse_time: parsing time "2024-13-01": month out of range`},
+		{`parse_time("2024-01-01", "2006-01-02", "Mars/Olympus")`, `parse_time: unknown time zone "Mars/Olympus"`},
+		{`format_time(in_zone(from_unix(0), "Asia/Tokyo"), "15:04 MST")`, "09:00 JST"},
+		{`in_zone(from_unix(0), 1)`, "time zone argument to `in_zone` must be STRING, got INTEGER"},
+		{`type(duration("1h30m"))`, "DURATION"},
+		{`duration("soon")`, `duration: time: invalid duration "soon"`},
+		{`seconds(duration("1m30s"))`, 90.0},
+		{`milliseconds(duration("2s"))`, 2000},
+		{`seconds(1)`, "argument to `seconds` must be DURATION, got INTEGER"},
+		{`format_time(from_unix(0) + duration("36h"), "2006-01-02 15:04")`, "1970-01-02 12:00"},
+		{`format_time(duration("1h") + from_unix(0), "15:04")`, "01:00"},
+		{`format_time(from_unix(0) - duration("1h"), "2006-01-02 15:04")`, "1969-12-31 23:00"},
+		{`milliseconds(from_unix(10) - from_unix(4))`, 6000},
+		{`milliseconds(duration("1s") + duration("500ms"))`, 1500},
+		{`milliseconds(duration("1s") - duration("1500ms"))`, -500},
+		{`milliseconds(duration("1s") * 3)`, 3000},
+		{`milliseconds(3 * duration("1s"))`, 3000},
+		{`milliseconds(duration("1s") / 4)`, 250},
+		{`duration("1h") / duration("30m")`, 2.0},
+		{`milliseconds(-duration("1s"))`, -1000},
+		{`duration("1s") / 0`, "division by zero"},
+		{`duration("2540400h") * 100`, "duration overflow"},
+		{`duration("1s") + 1`, "unknown operator: DURATION + INTEGER"},
+		{`duration("1s") * duration("1s")`, "unknown operator: DURATION * DURATION"},
+		{`from_unix(0) + from_unix(0)`, "unknown operator: TIME + TIME"},
+		{`from_unix(1) > from_unix(0)`, true},
+		{`from_unix(0) == in_zone(from_unix(0), "Asia/Tokyo")`, true},
+		{`duration("60s") == duration("1m")`, true},
+		{`duration("1s") < duration("1ms")`, false},
+		{`duration("1s") == 1`, false},
+		{`duration("1s") < 1`, "type mismatch: DURATION < INTEGER"},
+		{`sort_by([from_unix(3), from_unix(1), from_unix(2)], fn(t) { t })[0] == from_unix(1)`, true},
+		{`sleep(1)`, nil},
+		{`sleep(duration("1ms"))`, nil},
+		{`sleep("1s")`, "argument to `sleep` must be DURATION or INTEGER, got STRING"},
+	}
+
+	for _, tt := range tests {
+		testValue(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestTimeInspect(t *testing.T) {
+	tests := []struct {
+		obj      object.Object
+		expected string
+	}{
+		{&object.Time{Value: time.Date(2024, 2, 29, 13, 45, 0, 5, time.UTC)}, "2024-02-29T13:45:00.000000005Z"},
+		{&object.Duration{Value: 90 * time.Minute}, "1h30m0s"},
+	}
+	for _, tt := range tests {
+		if got := tt.obj.Inspect(); got != tt.expected {
+			t.Errorf("wrong inspect. expected=%q, got=%q", tt.expected, got)
+		}
+	}
+}
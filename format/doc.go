@@ -0,0 +1,259 @@
+package format
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// doc describes layout of the code, that depends on the width of lines:
+// groups are printed on one line, if they fit, and broken at lines otherwise.
+type doc interface{}
+
+type (
+	text string
+	// line is space, or nothing if soft, in flat group, and line break in broken one
+	line struct{ soft bool }
+	// hardline always breaks the line
+	hardline struct{}
+	// blankline keeps empty line of the source, it is ignored at the start of file and block
+	blankline struct{}
+	indent    []doc
+	concat    []doc
+	group     struct {
+		docs   []doc
+		broken bool // the group is broken regardless of width, e.g. block written on many lines
+		block  bool // the group is broken, if it doesn't fit, even inside of flat group
+	}
+	// comment is line comment. It either takes the whole line or trails the code on it.
+	// Lines with comments can't be joined, so groups with comments are broken.
+	comment struct {
+		text    string
+		ownLine bool
+		blank   bool // own line comment is preceded by empty line
+	}
+)
+
+const tabWidth = 4 // width of indentation for line width
+
+type printer struct {
+	width       int
+	out         strings.Builder
+	col         int
+	lineStart   bool // nothing, but indentation, is printed on the line
+	needNewline bool // line comment was printed, the next text must be on the next line
+	// line is broken by comment, so it continues the previous one and is indented more
+	continued bool
+	// the next line after the comment continues the line of the comment,
+	// which is the case for trailing comments and comments inside of the continued line
+	nextContinued bool
+	last          byte // the last printed byte, that is not whitespace
+}
+
+// item is doc to print with the indentation and the mode of its group.
+type item struct {
+	d    doc
+	ind  int
+	flat bool
+}
+
+// print prints the doc. Docs to print are kept on the stack,
+// because groups are measured together with the docs after them.
+func (p *printer) print(d doc) {
+	stack := []item{{d: d}}
+	push := func(docs []doc, ind int, flat bool) {
+		for i := len(docs) - 1; i >= 0; i-- {
+			stack = append(stack, item{docs[i], ind, flat})
+		}
+	}
+	for len(stack) > 0 {
+		it := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		ind, flat := it.ind, it.flat
+		switch d := it.d.(type) {
+		case text:
+			p.text(string(d), ind)
+		case line:
+			switch {
+			case !flat:
+				p.newline()
+			case p.needNewline:
+				p.breakComment()
+			case !d.soft:
+				p.text(" ", ind)
+			}
+		case hardline:
+			p.newline()
+		case blankline:
+			if p.needNewline {
+				p.breakComment()
+			}
+			p.blank()
+		case comment:
+			if d.ownLine {
+				if !p.lineStart {
+					p.continueLine()
+				}
+				if d.blank {
+					p.blank()
+				}
+			} else if !p.lineStart {
+				p.text(" ", ind)
+			}
+			p.text(d.text, ind)
+			p.last = '/'
+			p.needNewline = true
+			p.nextContinued = !d.ownLine || p.continued
+		case indent:
+			if !flat {
+				ind++
+			}
+			push(d, ind, flat)
+		case concat:
+			push(d, ind, flat)
+		case group:
+			switch {
+			case d.broken:
+				flat = false
+			case !flat, d.block:
+				start := p.col
+				if p.lineStart {
+					start = p.indentation(ind) * tabWidth
+				}
+				flat = fits(d, stack, p.width-start)
+			}
+			push(d.docs, ind, flat)
+		}
+	}
+}
+
+func (p *printer) text(s string, ind int) {
+	if p.needNewline {
+		p.breakComment()
+	}
+	if p.lineStart {
+		if s == " " {
+			return
+		}
+		ind = p.indentation(ind)
+		p.out.WriteString(strings.Repeat("\t", ind))
+		p.col = ind * tabWidth
+		p.lineStart = false
+	}
+	p.out.WriteString(s)
+	p.col += utf8.RuneCountInString(s)
+	if t := strings.TrimRight(s, " "); t != "" {
+		p.last = t[len(t)-1]
+	}
+}
+
+func (p *printer) newline() {
+	if out := p.out.String(); strings.HasSuffix(out, " ") { // space before own line comment
+		p.out.Reset()
+		p.out.WriteString(strings.TrimRight(out, " "))
+	}
+	p.out.WriteByte('\n')
+	p.col = 0
+	p.lineStart = true
+	p.needNewline = false
+	p.continued = false
+}
+
+// continueLine breaks the line in the middle, e.g. before own line comment.
+func (p *printer) continueLine() {
+	p.newline()
+	p.continued = true
+}
+
+// breakComment breaks the line after line comment.
+func (p *printer) breakComment() {
+	continued := p.nextContinued
+	p.newline()
+	p.continued = continued
+}
+
+func (p *printer) indentation(ind int) int {
+	if p.continued {
+		return ind + 1
+	}
+	return ind
+}
+
+// blank prints empty line, unless it is the first line of the file or of the block or list.
+func (p *printer) blank() {
+	if !p.lineStart || p.out.Len() == 0 || strings.HasSuffix(p.out.String(), "\n\n") {
+		return
+	}
+	switch p.last {
+	case '{', '(', '[':
+		return
+	}
+	p.out.WriteByte('\n')
+}
+
+// fits reports whether the group fits into width, when it is printed on one line,
+// together with the rest of docs up to the next line break.
+// Only the text up to the first line break of broken groups counts,
+// so e.g. call with multiline fn argument stays on one line with the start of fn.
+func fits(g group, rest []item, width int) bool {
+	stack := make([]measured, 0, len(rest)+len(g.docs))
+	for _, it := range rest {
+		stack = append(stack, measured{it.d, it.flat, true})
+	}
+	// blocks inside of flat block are flat too
+	return measure(pushMeasured(stack, g.docs, true, !g.block), width)
+}
+
+type measured struct {
+	d    doc
+	flat bool
+	// block in flat group may be broken, if it doesn't fit
+	free bool
+}
+
+func pushMeasured(stack []measured, docs []doc, flat, free bool) []measured {
+	for i := len(docs) - 1; i >= 0; i-- {
+		stack = append(stack, measured{docs[i], flat, free})
+	}
+	return stack
+}
+
+// measure reports whether docs of the stack up to the first line break fit into width.
+// Blocks, that are not broken yet, are measured both on one line and broken,
+// as the printer breaks them, if they don't fit. So the source is measured
+// the same way, when its blocks are broken after formatting.
+func measure(stack []measured, width int) bool {
+	for len(stack) > 0 && width >= 0 {
+		it := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		switch d := it.d.(type) {
+		case text:
+			width -= utf8.RuneCountInString(string(d))
+		case line:
+			if !it.flat {
+				return true
+			}
+			if !d.soft {
+				width--
+			}
+		case hardline:
+			return true
+		case comment:
+			return false
+		case indent:
+			stack = pushMeasured(stack, d, it.flat, it.free)
+		case concat:
+			stack = pushMeasured(stack, d, it.flat, it.free)
+		case group:
+			flat := it.flat && !d.broken
+			if flat && d.block && it.free {
+				oneLine := pushMeasured(append([]measured(nil), stack...), d.docs, true, false)
+				if measure(oneLine, width) {
+					return true
+				}
+				flat = false
+			}
+			stack = pushMeasured(stack, d.docs, flat, it.free)
+		}
+	}
+	return width >= 0
+}
@@ -0,0 +1,448 @@
+// Package format prints plang source in the canonical layout:
+// statements on separate lines, blocks indented with tabs, single spaces around
+// binary operators and after commas. Blocks written on one line stay on one line,
+// if they fit. Calls, arrays, hashes and parameters, that don't fit into the line,
+// are broken into one element per line. Comments and single empty lines are kept.
+// Formatting of formatted source doesn't change it.
+package format
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pechorka/plang/ast"
+	"github.com/pechorka/plang/cst"
+	"github.com/pechorka/plang/diag"
+	"github.com/pechorka/plang/lexer"
+	"github.com/pechorka/plang/token"
+)
+
+// DefaultWidth is the width of lines, that Source keeps to.
+const DefaultWidth = 100
+
+// SyntaxError is returned for source, that can't be parsed.
+// Such source is not formatted, because parts of it may be misplaced.
+type SyntaxError struct {
+	Diagnostics []diag.Diagnostic
+}
+
+func (e *SyntaxError) Error() string {
+	if len(e.Diagnostics) == 1 {
+		return e.Diagnostics[0].Error()
+	}
+	return fmt.Sprintf("%s (and %d more errors)", e.Diagnostics[0].Error(), len(e.Diagnostics)-1)
+}
+
+// Source formats the source with lines of DefaultWidth.
+func Source(src []byte) ([]byte, error) {
+	return Config{Width: DefaultWidth}.Source(src)
+}
+
+// Config controls the layout of formatted source.
+type Config struct {
+	Width int // width of lines, tab counts as 4 columns
+}
+
+func (c Config) Source(src []byte) ([]byte, error) {
+	f, diags := cst.Parse(string(src))
+	if len(diags) != 0 {
+		return nil, &SyntaxError{Diagnostics: diags}
+	}
+	return []byte(c.File(f)), nil
+}
+
+// File returns formatted source of the syntax tree, that has no errors.
+func (c Config) File(f *cst.File) string {
+	b := newBuilder(f)
+	p := printer{width: c.Width, lineStart: true}
+	p.print(b.program(f))
+	if p.out.Len() > 0 {
+		p.newline()
+	}
+	return p.out.String()
+}
+
+type builder struct {
+	// comments, that are on the line of the token after it
+	trailing map[*cst.Token][]comment
+	// comments on the lines before the token
+	leading map[*cst.Token][]comment
+	// token is preceded by empty line
+	blankBefore map[*cst.Token]bool
+	// tokens, which are not separated by space from the next or previous token
+	unary      map[*cst.Token]bool // operators of prefix expressions
+	sliceColon map[*cst.Token]bool
+}
+
+func newBuilder(f *cst.File) *builder {
+	b := &builder{
+		trailing:    make(map[*cst.Token][]comment),
+		leading:     make(map[*cst.Token][]comment),
+		blankBefore: make(map[*cst.Token]bool),
+		unary:       make(map[*cst.Token]bool),
+		sliceColon:  make(map[*cst.Token]bool),
+	}
+	tokens := append(f.Root.Tokens(), f.EOF)
+	for i, tok := range tokens {
+		newlines := 0
+		sawNewline := false
+		for _, tr := range tok.Leading {
+			switch tr.Kind {
+			case lexer.Whitespace:
+				n := strings.Count(tr.Text, "\n")
+				newlines += n
+				sawNewline = sawNewline || n > 0
+			case lexer.Comment:
+				c := comment{text: strings.TrimRight(tr.Text, " \t\r")}
+				if !sawNewline && i > 0 {
+					b.trailing[tokens[i-1]] = append(b.trailing[tokens[i-1]], c)
+				} else {
+					c.ownLine = true
+					c.blank = newlines > 1
+					b.leading[tok] = append(b.leading[tok], c)
+				}
+				newlines = 0
+			}
+		}
+		b.blankBefore[tok] = newlines > 1
+	}
+	b.markTokens(f.Root)
+	return b
+}
+
+// markTokens finds tokens, that need special spacing.
+func (b *builder) markTokens(n *cst.Node) {
+	for i, c := range n.Children {
+		switch c := c.(type) {
+		case *cst.Node:
+			b.markTokens(c)
+		case *cst.Token:
+			switch n.AST.(type) {
+			case *ast.PrefixExpression:
+				if i == 0 {
+					b.unary[c] = true
+				}
+			case *ast.SliceExpression:
+				if c.Type == token.COLON {
+					b.sliceColon[c] = true
+				}
+			}
+		}
+	}
+}
+
+// token returns the token with its comments.
+func (b *builder) token(tok *cst.Token) doc {
+	var d concat
+	for _, c := range b.leading[tok] {
+		d = append(d, c)
+	}
+	if b.blankBefore[tok] {
+		d = append(d, blankline{})
+	}
+	d = append(d, text(tok.Text))
+	for _, c := range b.trailing[tok] {
+		d = append(d, c)
+	}
+	return d
+}
+
+// comments returns comments of the token, that is not printed itself, e.g. closing brace.
+// They are printed at the indentation of the enclosed code.
+func (b *builder) comments(tok *cst.Token) doc {
+	var d concat
+	for _, c := range b.leading[tok] {
+		d = append(d, c)
+	}
+	return d
+}
+
+func (b *builder) program(f *cst.File) doc {
+	var d concat
+	for i, c := range f.Root.Children {
+		if i > 0 {
+			d = append(d, hardline{})
+		}
+		d = append(d, b.element(c))
+	}
+	if len(b.leading[f.EOF]) > 0 && len(d) > 0 {
+		d = append(d, hardline{})
+	}
+	d = append(d, b.comments(f.EOF))
+	return d
+}
+
+func (b *builder) element(e cst.Element) doc {
+	switch e := e.(type) {
+	case *cst.Token:
+		return b.token(e)
+	case *cst.Node:
+		return b.node(e)
+	}
+	return nil
+}
+
+func (b *builder) node(n *cst.Node) doc {
+	switch n.AST.(type) {
+	case *ast.BlockStatement:
+		return b.block(n.Children)
+	case *ast.StructStatement:
+		return b.structStatement(n)
+	case *ast.CallExpression, *ast.FnExpression:
+		return b.withList(n.Children, token.LPAREN, token.RPAREN)
+	case *ast.ArrayLiteral:
+		return b.withList(n.Children, token.LBRACKET, token.RBRACKET)
+	case *ast.HashLiteral:
+		return b.withList(n.Children, token.LBRACE, token.RBRACE)
+	}
+	return b.join(n.Children)
+}
+
+// join prints elements on one line with spaces between tokens, where they are needed.
+func (b *builder) join(elems []cst.Element) concat {
+	var d concat
+	for i, e := range elems {
+		if i > 0 && b.space(lastToken(elems[i-1]), firstToken(e)) {
+			d = append(d, text(" "))
+		}
+		d = append(d, b.element(e))
+	}
+	return d
+}
+
+func (b *builder) space(prev, next *cst.Token) bool {
+	switch {
+	case b.unary[prev], b.sliceColon[prev], b.sliceColon[next]:
+		return false
+	case prev.Type == token.LPAREN, prev.Type == token.LBRACKET, prev.Type == token.DOT:
+		return false
+	}
+	switch next.Type {
+	case token.RPAREN, token.RBRACKET, token.COMMA, token.SEMICOLON, token.COLON, token.DOT:
+		return false
+	case token.LPAREN, token.LBRACKET: // call or index
+		switch prev.Type {
+		case token.IDENT, token.STRING, token.FUNCTION, token.RPAREN, token.RBRACKET, token.RBRACE:
+			return false
+		}
+	}
+	return true
+}
+
+// withList prints elements with the list between open and close tokens,
+// that is broken into lines, if it doesn't fit.
+func (b *builder) withList(elems []cst.Element, open, close token.Type) doc {
+	start, end := -1, -1
+	for i, e := range elems {
+		if tok, ok := e.(*cst.Token); ok {
+			switch {
+			case tok.Type == open && start < 0:
+				start = i
+			case tok.Type == close:
+				end = i
+			}
+		}
+	}
+	if start < 0 || end < start {
+		return b.join(elems)
+	}
+
+	d := b.join(elems[:start])
+	if start > 0 && b.space(lastToken(elems[start-1]), elems[start].(*cst.Token)) {
+		d = append(d, text(" "))
+	}
+	d = append(d, b.list(elems[start:end+1]))
+	if end+1 < len(elems) {
+		d = append(d, b.join(elems[end:])[1:]...)
+	}
+	return d
+}
+
+// list prints comma separated elements between the first and the last tokens.
+func (b *builder) list(elems []cst.Element) doc {
+	open, close := elems[0].(*cst.Token), elems[len(elems)-1].(*cst.Token)
+	var items [][]cst.Element
+	var commas []*cst.Token
+	var item []cst.Element
+	for _, e := range elems[1 : len(elems)-1] {
+		if tok, ok := e.(*cst.Token); ok && tok.Type == token.COMMA {
+			items = append(items, item)
+			commas = append(commas, tok)
+			item = nil
+			continue
+		}
+		item = append(item, e)
+	}
+	if len(item) > 0 {
+		items = append(items, item)
+	}
+
+	if len(items) == 0 && len(b.leading[close]) == 0 {
+		return concat{b.token(open), b.token(close)}
+	}
+
+	var inner concat
+	for i, item := range items {
+		if i > 0 {
+			inner = append(inner, line{})
+		}
+		inner = append(inner, b.join(item))
+		if i < len(commas) {
+			if i < len(items)-1 {
+				inner = append(inner, b.token(commas[i]))
+			} else {
+				inner = append(inner, b.commentsOf(commas[i])) // trailing comma is dropped
+			}
+		}
+	}
+	if len(b.leading[close]) > 0 && len(items) > 0 {
+		inner = append(inner, line{soft: true})
+	}
+	inner = append(inner, b.comments(close))
+	return concat{
+		group{docs: []doc{b.token(open), indent{line{soft: true}, inner}, line{soft: true}, text(close.Text)}},
+		b.trailingOf(close), // comment after the list doesn't break it
+	}
+}
+
+// trailingOf returns comments on the line after the token.
+func (b *builder) trailingOf(tok *cst.Token) doc {
+	var d concat
+	for _, c := range b.trailing[tok] {
+		d = append(d, c)
+	}
+	return d
+}
+
+// commentsOf returns all comments of the token, that is dropped.
+func (b *builder) commentsOf(tok *cst.Token) doc {
+	return concat{b.comments(tok), b.trailingOf(tok)}
+}
+
+// block prints statements between braces. Blocks with statements on separate lines
+// in the source keep them on separate lines, other blocks are broken only if they don't fit.
+func (b *builder) block(elems []cst.Element) doc {
+	open, close := elems[0].(*cst.Token), elems[len(elems)-1].(*cst.Token)
+	stmts := elems[1 : len(elems)-1]
+	if close.Type != token.RBRACE { // can't happen in source without errors
+		return b.join(elems)
+	}
+	return b.braced(open, stmts, close, func(stmt cst.Element) doc { return b.element(stmt) })
+}
+
+// braced prints items between braces on one line or each on its own line.
+func (b *builder) braced(open *cst.Token, items []cst.Element, close *cst.Token, item func(cst.Element) doc) doc {
+	if len(items) == 0 && len(b.leading[close]) == 0 {
+		return concat{b.token(open), b.token(close)}
+	}
+
+	multiline := hasNewline(close)
+	for _, it := range items {
+		multiline = multiline || hasNewline(firstToken(it))
+	}
+	sep := doc(line{})
+	if multiline {
+		sep = hardline{}
+	}
+	var inner concat
+	for _, it := range items {
+		inner = append(inner, sep, item(it))
+	}
+	if len(b.leading[close]) > 0 {
+		inner = append(inner, sep)
+	}
+	inner = append(inner, b.comments(close))
+	return concat{
+		group{docs: []doc{b.token(open), indent(inner), sep, text(close.Text)}, broken: multiline, block: true},
+		b.trailingOf(close),
+	}
+}
+
+func (b *builder) structStatement(n *cst.Node) doc {
+	start, end := -1, -1
+	for i, c := range n.Children {
+		if tok, ok := c.(*cst.Token); ok {
+			switch {
+			case tok.Type == token.LBRACE && start < 0:
+				start = i
+			case tok.Type == token.RBRACE:
+				end = i
+			}
+		}
+	}
+	if start < 0 || end < start {
+		return b.join(n.Children)
+	}
+
+	// member is field or method from fn to its body with separators after it
+	var members []cst.Element
+	var member []cst.Element
+	for _, c := range n.Children[start+1 : end] {
+		if tok, ok := c.(*cst.Token); ok && (tok.Type == token.IDENT || tok.Type == token.FUNCTION) && memberDone(member) {
+			members = append(members, &cst.Node{Children: member})
+			member = nil
+		}
+		member = append(member, c)
+	}
+	if len(member) > 0 {
+		members = append(members, &cst.Node{Children: member})
+	}
+
+	d := concat{b.join(n.Children[:start]), text(" ")}
+	d = append(d, b.braced(n.Children[start].(*cst.Token), members, n.Children[end].(*cst.Token), func(m cst.Element) doc {
+		return b.join(m.(*cst.Node).Children)
+	}))
+	if end+1 < len(n.Children) {
+		d = append(d, b.join(n.Children[end:])[1:]...)
+	}
+	return d
+}
+
+// memberDone reports whether the next identifier starts new member of struct:
+// identifiers of method are its name and params, which are before its body.
+func memberDone(member []cst.Element) bool {
+	if len(member) == 0 {
+		return false
+	}
+	if tok, ok := member[0].(*cst.Token); !ok || tok.Type != token.FUNCTION {
+		return true
+	}
+	for _, e := range member {
+		if _, ok := e.(*cst.Node); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// hasNewline reports whether the token starts a line in the source.
+func hasNewline(tok *cst.Token) bool {
+	for _, tr := range tok.Leading {
+		if strings.Contains(tr.Text, "\n") {
+			return true
+		}
+	}
+	return false
+}
+
+func firstToken(e cst.Element) *cst.Token {
+	for {
+		switch el := e.(type) {
+		case *cst.Token:
+			return el
+		case *cst.Node:
+			e = el.Children[0]
+		}
+	}
+}
+
+func lastToken(e cst.Element) *cst.Token {
+	for {
+		switch el := e.(type) {
+		case *cst.Token:
+			return el
+		case *cst.Node:
+			e = el.Children[len(el.Children)-1]
+		}
+	}
+}
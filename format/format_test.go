@@ -0,0 +1,243 @@
+package format
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pechorka/plang/lexer"
+	"github.com/pechorka/plang/parser"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"", ""},
+		{"  \n\n ", ""},
+		{"let x=1+2*3", "let x = 1 + 2 * 3\n"},
+		{"let  add =fn(a,b){return a+b};\r\nputs( add(1 , 2) ) ;", "let add = fn(a, b) { return a + b };\nputs(add(1, 2));\n"},
+		{"let a = 1; let b = -a; !true", "let a = 1;\nlet b = -a;\n!true\n"},
+		{"let s = xs[1:len(xs)][ : 2]; p.x.y()", "let s = xs[1:len(xs)][:2];\np.x.y()\n"},
+		{"if(x>1){x}else{ -x }", "if (x > 1) { x } else { -x }\n"},
+		{"try{throw \"e\"}catch(e){e}finally{0}", "try { throw \"e\" } catch (e) { e } finally { 0 }\n"},
+		{"for(x in [1,2]){puts(x)}", "for (x in [1, 2]) { puts(x) }\n"},
+		{"let h = {\"a\":1,\"b\":[],}; let e = {}", "let h = {\"a\": 1, \"b\": []};\nlet e = {}\n"},
+		{"struct Point{x,y;fn len(){this.x}}", "struct Point { x, y; fn len() { this.x } }\n"},
+		{"struct P {\nx\nfn get() {\nthis.x\n}\n}", "struct P {\n\tx\n\tfn get() {\n\t\tthis.x\n\t}\n}\n"},
+		// blocks written on many lines keep statements on separate lines
+		{"let f = fn(x) {\nlet y = x\n  y * 2 }", "let f = fn(x) {\n\tlet y = x\n\ty * 2\n}\n"},
+		{"fn() {\n\n}", "fn() {}\n"},
+		// single empty lines are kept, except at the start and end of blocks
+		{"let a = 1\n\n\n\nlet b = 2\n\n", "let a = 1\n\nlet b = 2\n"},
+		{"if (x) {\n\n  1\n\n}", "if (x) {\n\t1\n}\n"},
+		// comments
+		{"// header\n\n\nlet x = 1 // one\n\n\n// two\nlet y = 2\n// end", "// header\n\nlet x = 1 // one\n\n// two\nlet y = 2\n// end\n"},
+		{"let f = fn() { x // trailing\n}", "let f = fn() {\n\tx // trailing\n}\n"},
+		{"let f = fn() {\n  x\n  // last\n}", "let f = fn() {\n\tx\n\t// last\n}\n"},
+		{"f(1, // one\n2)", "f(\n\t1, // one\n\t2\n)\n"},
+		// lines continued after comments are indented
+		{"let x = 1 // a\n + 2;", "let x = 1 // a\n\t+ 2;\n"},
+		{"let f = fn() {\nlet x = 1 // a\n + 2 // b\n * 3;\nx\n}", "let f = fn() {\n\tlet x = 1 // a\n\t\t+ 2 // b\n\t\t* 3;\n\tx\n}\n"},
+		{"let x = 1\n// a\n+ 2\nlet y = 3", "let x = 1\n\t// a\n\t+ 2\nlet y = 3\n"},
+		{"let h = {\"a\": 1, // one\n}", "let h = {\n\t\"a\": 1 // one\n}\n"},
+		// long lists are broken into lines
+		{
+			"let result = some_function(first_argument, second_argument, third_argument, fourth_argument_is_quite_long)",
+			"let result = some_function(\n\tfirst_argument,\n\tsecond_argument,\n\tthird_argument,\n\tfourth_argument_is_quite_long\n)\n",
+		},
+		{
+			"let xs = [\"aaaaaaaaaaaaaaaaaaaa\", \"bbbbbbbbbbbbbbbbbbbb\", \"cccccccccccccccccccc\", \"dddddddddddddddddddd\", [1, 2]]",
+			"let xs = [\n\t\"aaaaaaaaaaaaaaaaaaaa\",\n\t\"bbbbbbbbbbbbbbbbbbbb\",\n\t\"cccccccccccccccccccc\",\n\t\"dddddddddddddddddddd\",\n\t[1, 2]\n]\n",
+		},
+		{
+			"let h = {\"aaaaaaaaaaaaaaaaaaaaaaaaa\": 1, \"bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb\": 2, \"cccccccccccccccccccccccc\": [3, 4]}",
+			"let h = {\n\t\"aaaaaaaaaaaaaaaaaaaaaaaaa\": 1,\n\t\"bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb\": 2,\n\t\"cccccccccccccccccccccccc\": [3, 4]\n}\n",
+		},
+		// multiline fn argument doesn't break the call
+		{"map(xs, fn(x) {\nx * 2\n}, 0)", "map(xs, fn(x) {\n\tx * 2\n}, 0)\n"},
+	}
+
+	for i, tt := range tests {
+		out, err := Source([]byte(tt.input))
+		if err != nil {
+			t.Errorf("test[%d]: unexpected error: %s", i, err)
+			continue
+		}
+		if string(out) != tt.expected {
+			t.Errorf("test[%d]: wrong output.\nexpected=%q\ngot=     %q", i, tt.expected, string(out))
+		}
+	}
+}
+
+func TestConfig_width(t *testing.T) {
+	src := []byte("let f = fn(first, second) { add(first, second) }")
+	tests := []struct {
+		width    int
+		expected string
+	}{
+		{60, "let f = fn(first, second) { add(first, second) }\n"},
+		{40, "let f = fn(first, second) {\n\tadd(first, second)\n}\n"},
+		{20, "let f = fn(\n\tfirst,\n\tsecond\n) {\n\tadd(\n\t\tfirst,\n\t\tsecond\n\t)\n}\n"},
+	}
+
+	for i, tt := range tests {
+		out, err := Config{Width: tt.width}.Source(src)
+		if err != nil {
+			t.Fatalf("test[%d]: unexpected error: %s", i, err)
+		}
+		if string(out) != tt.expected {
+			t.Errorf("test[%d]: wrong output.\nexpected=%q\ngot=     %q", i, tt.expected, string(out))
+		}
+	}
+}
+
+func TestSource_idempotent(t *testing.T) {
+	tests := []string{
+		"let  add =fn(a,b){\n\treturn a+b // sum\n};\r\nputs( add(1 , 2) ) ;\n\n",
+		"struct Point { x, y\n fn len() { this.x } };let p = Point(1, 2); p.len()",
+		"let f = fn(x) {\n// c\n\nx\n\n// tail\n}\nf(1, // one\n2)",
+		"f(fn() {\n x\n}, // c\n 2)",
+		"let x = 1 // a\n + 2;",
+		"let x = 1\n// a\n+ 2 // b\n* 3",
+		"let h = {\"a\": [1, // one\n2], // two\n}", // one pair, because hashes print pairs in random order
+		"reduce(xs, fn(acc, x) { if (pred(x)) { [push(acc[0], x), acc[1]] } else { [acc[0], push(acc[1], x)] } }, [[], []])",
+		`f(if (f()) {return "s";for (i in 2.5) {"s";return "s";}} else {for (i in true) {}  return 2.5;});`,
+	}
+	files, err := filepath.Glob("../prelude/*.pl")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no prelude files: %v", err)
+	}
+	for _, name := range files {
+		src, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tests = append(tests, string(src))
+	}
+
+	for i, src := range tests {
+		first, err := Source([]byte(src))
+		if err != nil {
+			t.Fatalf("test[%d]: unexpected error: %s", i, err)
+		}
+		second, err := Source(first)
+		if err != nil {
+			t.Fatalf("test[%d]: formatted source has errors: %s\n%s", i, err, first)
+		}
+		if string(first) != string(second) {
+			t.Errorf("test[%d]: formatting is not idempotent.\nfirst= %q\nsecond=%q", i, first, second)
+		}
+		if expected, got := program(t, src), program(t, string(first)); expected != got {
+			t.Errorf("test[%d]: program is changed.\nexpected=%q\ngot=     %q", i, expected, got)
+		}
+	}
+}
+
+// TestSource_random checks, that formatting of random programs is idempotent.
+func TestSource_random(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 500; i++ {
+		var src strings.Builder
+		for n := r.Intn(3) + 1; n > 0; n-- {
+			src.WriteString(randomStatement(r, 3))
+		}
+		for _, width := range []int{DefaultWidth, 40} {
+			first, err := Config{Width: width}.Source([]byte(src.String()))
+			if err != nil {
+				t.Fatalf("test[%d]: unexpected error: %s\n%s", i, err, src.String())
+			}
+			second, err := Config{Width: width}.Source(first)
+			if err != nil {
+				t.Fatalf("test[%d]: formatted source has errors: %s\n%s", i, err, first)
+			}
+			if string(first) != string(second) {
+				t.Errorf("test[%d]: formatting with width %d is not idempotent.\nsource=%q\nfirst= %q\nsecond=%q",
+					i, width, src.String(), first, second)
+			}
+		}
+	}
+}
+
+func randomStatement(r *rand.Rand, depth int) string {
+	seps := []string{";", "; ", ";\n", "; // c\n"}
+	sep := seps[r.Intn(len(seps))]
+	switch r.Intn(6) {
+	case 0:
+		return "let x = " + randomExpression(r, depth) + sep
+	case 1:
+		return "return " + randomExpression(r, depth) + sep
+	case 2:
+		return fmt.Sprintf("for (i in %s) %s%s", randomExpression(r, depth), randomBlock(r, depth), sep)
+	}
+	return randomExpression(r, depth) + sep
+}
+
+func randomExpression(r *rand.Rand, depth int) string {
+	atoms := []string{"1", "2.5", `"s"`, "true", "x", "f()", "xs[0]", "-x"}
+	if depth <= 0 {
+		return atoms[r.Intn(len(atoms))]
+	}
+	switch r.Intn(8) {
+	case 0:
+		return fmt.Sprintf("if (%s) %s else %s", randomExpression(r, depth-1), randomBlock(r, depth-1), randomBlock(r, depth-1))
+	case 1:
+		return "fn(a, b) " + randomBlock(r, depth-1)
+	case 2:
+		return "f(" + randomList(r, depth-1) + ")"
+	case 3:
+		return "[" + randomList(r, depth-1) + "]"
+	case 4:
+		return fmt.Sprintf(`{"k": %s}`, randomExpression(r, depth-1))
+	case 5:
+		return randomExpression(r, depth-1) + " + " + randomExpression(r, depth-1)
+	}
+	return atoms[r.Intn(len(atoms))]
+}
+
+func randomList(r *rand.Rand, depth int) string {
+	var items []string
+	for n := r.Intn(4); n > 0; n-- {
+		items = append(items, randomExpression(r, depth))
+	}
+	return strings.Join(items, ", ")
+}
+
+func randomBlock(r *rand.Rand, depth int) string {
+	var b strings.Builder
+	b.WriteString("{")
+	for n := r.Intn(3); n > 0; n-- {
+		b.WriteString(randomStatement(r, depth))
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+func TestSource_syntaxError(t *testing.T) {
+	_, err := Source([]byte("let x = ;\nlet = 2"))
+	var syntaxErr *SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		t.Fatalf("error is not syntax error: %v", err)
+	}
+	if len(syntaxErr.Diagnostics) != 2 {
+		t.Errorf("expected 2 diagnostics, got %d: %v", len(syntaxErr.Diagnostics), syntaxErr.Diagnostics)
+	}
+	expected := `1:9: no prefix func for ";" token type (and 1 more errors)`
+	if err.Error() != expected {
+		t.Errorf("wrong error. expected=%q, got=%q", expected, err.Error())
+	}
+}
+
+func program(t *testing.T, src string) string {
+	p := parser.New(lexer.NewFromString(src))
+	prog := p.Parse()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	return prog.String()
+}
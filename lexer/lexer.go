@@ -2,11 +2,13 @@ package lexer
 
 import (
 	"bufio"
+	"fmt"
 	"io"
 	"strings"
 	"unicode"
 	"unicode/utf8"
 
+	"github.com/pechorka/plang/diag"
 	"github.com/pechorka/plang/token"
 )
 
@@ -14,11 +16,73 @@ type Lexer struct {
 	r           bufio.Reader
 	currentRune rune
 	nextRune    rune
+	// raw byte of the rune, if it is utf8.RuneError because of invalid encoding
+	currentRaw string
+	nextRaw    string
+	currentPos token.Position
+	nextPos    token.Position
+	readPos    token.Position // position of the rune that will be read next
+
+	readErr         error // failure of the reader, the input ends there
+	readErrReported bool
+	errors          []Error
+
+	text      strings.Builder // source text of runes read since the last reset
+	tokenText string          // source text of the last token
+	trivia    []Trivia        // trivia before the last token
+}
+
+type TriviaKind int
+
+const (
+	Whitespace TriviaKind = iota
+	Comment
+)
+
+// Trivia is source text between tokens, that doesn't affect the program.
+type Trivia struct {
+	Kind TriviaKind
+	Text string
+	Pos  token.Position
+}
+
+// eof is the rune after the end of input.
+const eof rune = -1
+
+// Error describes malformed input. Every INVALID token has an error,
+// which is the last one in Errors, when the token is returned.
+type Error struct {
+	Pos    token.Position
+	Text   string // the offending text
+	Code   diag.Code
+	Reason string
+}
+
+func (e Error) Error() string {
+	return e.Pos.String() + ": " + e.Reason
+}
+
+func (e Error) Diagnostic() diag.Diagnostic {
+	return diag.Diagnostic{
+		Severity: diag.Error,
+		Code:     e.Code,
+		Message:  e.Reason,
+		Primary:  diag.SpanOf(e.Pos, e.Text),
+	}
 }
 
 func New(r io.Reader) *Lexer {
+	return NewNamed("", r)
+}
+
+// NewNamed returns lexer, which sets the name of the source in positions of tokens,
+// so positions from different sources can be told apart.
+func NewNamed(name string, r io.Reader) *Lexer {
 	l := &Lexer{
-		r: *bufio.NewReader(r),
+		r:           *bufio.NewReader(r),
+		currentRune: eof,
+		nextRune:    eof,
+		readPos:     token.Position{Line: 1, Column: 1, Source: name},
 	}
 	l.readRune()
 	l.readRune()
@@ -29,9 +93,36 @@ func NewFromString(in string) *Lexer {
 	return New(strings.NewReader(in))
 }
 
-func (l *Lexer) Next() (tok token.Token) {
-	l.skipWhitespace()
+// Errors returns errors of the tokens read so far.
+func (l *Lexer) Errors() []Error {
+	return l.errors
+}
+
+func (l *Lexer) Next() token.Token {
+	l.trivia = nil
+	l.skipWhitespaceAndComments()
 
+	pos := l.currentPos
+	l.text.Reset()
+	tok := l.next()
+	tok.Pos = pos
+	l.tokenText = l.text.String()
+	return tok
+}
+
+// Text returns source text of the last token. Unlike literal,
+// it is exactly as in the source, e.g. strings have quotes.
+func (l *Lexer) Text() string {
+	return l.tokenText
+}
+
+// Trivia returns whitespace and comments between the last token and the previous one.
+// Source is the concatenation of trivia and text of all tokens up to EOF.
+func (l *Lexer) Trivia() []Trivia {
+	return l.trivia
+}
+
+func (l *Lexer) next() (tok token.Token) {
 	switch l.currentRune {
 	case '=':
 		switch l.nextRune {
@@ -52,6 +143,8 @@ func (l *Lexer) Next() (tok token.Token) {
 		tok = l.newToken(token.SEMICOLON)
 	case ':':
 		tok = l.newToken(token.COLON)
+	case '.':
+		tok = l.newToken(token.DOT)
 	case '(':
 		tok = l.newToken(token.LPAREN)
 	case ')':
@@ -82,12 +175,13 @@ func (l *Lexer) Next() (tok token.Token) {
 	case '>':
 		tok = l.newToken(token.GT)
 	case '"':
-		l.readRune() // skip quote
 		tok = l.readString()
-	case 0:
+	case eof:
+		if l.readErr != nil && !l.readErrReported {
+			l.readErrReported = true
+			return l.errorToken(l.currentPos, "", diag.ReadFailure, "read error: "+l.readErr.Error())
+		}
 		tok.Type = token.EOF
-	case utf8.RuneError:
-		tok = l.newToken(token.INVALID)
 	default:
 		return l.multiRuneToken() // return early to avoid l.readRune()
 	}
@@ -98,17 +192,63 @@ func (l *Lexer) Next() (tok token.Token) {
 }
 
 func (l *Lexer) readRune() {
-	l.currentRune = l.nextRune
-	var err error
-	l.nextRune, _, err = l.r.ReadRune() // TODO handle error
-	if err == io.EOF {
-		l.nextRune = 0
+	switch {
+	case l.currentRaw != "":
+		l.text.WriteString(l.currentRaw)
+	case l.currentRune != eof:
+		l.text.WriteRune(l.currentRune)
+	}
+	l.currentRune, l.currentRaw = l.nextRune, l.nextRaw
+	l.currentPos = l.nextPos
+	l.nextPos = l.readPos
+	l.nextRaw = ""
+	if l.readErr != nil {
+		l.nextRune = eof
+		return
+	}
+	r, size, err := l.r.ReadRune()
+	if err != nil {
+		if err != io.EOF {
+			l.readErr = err
+		}
+		l.nextRune = eof
+		return
+	}
+	if r == utf8.RuneError && size == 1 {
+		l.r.UnreadRune()
+		b, _ := l.r.ReadByte()
+		l.nextRaw = string([]byte{b})
+	}
+	l.nextRune = r
+	if l.nextRune == '\n' {
+		l.readPos.Line++
+		l.readPos.Column = 1
+	} else {
+		l.readPos.Column++
 	}
 }
 
-func (l *Lexer) skipWhitespace() {
-	for unicode.IsSpace(l.currentRune) {
-		l.readRune()
+// skipWhitespaceAndComments skips whitespace and line comments, that start with //.
+// Skipped text is kept as trivia of the next token.
+func (l *Lexer) skipWhitespaceAndComments() {
+	for {
+		if unicode.IsSpace(l.currentRune) {
+			pos := l.currentPos
+			l.text.Reset()
+			for unicode.IsSpace(l.currentRune) {
+				l.readRune()
+			}
+			l.trivia = append(l.trivia, Trivia{Kind: Whitespace, Text: l.text.String(), Pos: pos})
+		}
+		if l.currentRune != '/' || l.nextRune != '/' {
+			return
+		}
+		pos := l.currentPos
+		l.text.Reset()
+		for l.currentRune != '\n' && l.currentRune != eof {
+			l.readRune()
+		}
+		l.trivia = append(l.trivia, Trivia{Kind: Comment, Text: l.text.String(), Pos: pos})
 	}
 }
 
@@ -119,20 +259,36 @@ func (l *Lexer) newToken(tt token.Type) token.Token {
 	}
 }
 
+// errorToken records error and returns INVALID token with the offending text.
+func (l *Lexer) errorToken(pos token.Position, text string, code diag.Code, reason string) token.Token {
+	l.errors = append(l.errors, Error{Pos: pos, Text: text, Code: code, Reason: reason})
+	return token.Token{Type: token.INVALID, Literal: text}
+}
+
 func (l *Lexer) multiRuneToken() token.Token {
 	switch {
 	case isLetter(l.currentRune):
 		return l.readIdent()
-	case unicode.IsDigit(l.currentRune):
+	case isDecimalDigit(l.currentRune):
 		return l.readNumber()
-	default:
-		return l.newToken(token.INVALID)
 	}
+	tok := l.strayRune()
+	l.readRune()
+	return tok
+}
+
+// strayRune reports rune, that can't start a token.
+func (l *Lexer) strayRune() token.Token {
+	if l.currentRaw != "" {
+		return l.errorToken(l.currentPos, l.currentRaw, diag.InvalidEncoding, fmt.Sprintf("invalid UTF-8 encoding %q", l.currentRaw))
+	}
+	return l.errorToken(l.currentPos, string(l.currentRune), diag.InvalidCharacter, fmt.Sprintf("unexpected character %q", l.currentRune))
 }
 
+// readIdent reads identifier: a letter or underscore followed by letters, underscores and digits.
 func (l *Lexer) readIdent() (tok token.Token) {
 	var buf strings.Builder
-	for isLetter(l.currentRune) {
+	for isLetter(l.currentRune) || unicode.IsDigit(l.currentRune) {
 		buf.WriteRune(l.currentRune)
 		l.readRune()
 	}
@@ -141,23 +297,123 @@ func (l *Lexer) readIdent() (tok token.Token) {
 	return tok
 }
 
+// readNumber reads integer or float literal. Integers are decimal
+// or have 0x, 0o or 0b prefix, floats are always decimal.
+// Underscores may separate digits, e.g. 1_000_000 or 0xFF_FF.
+// Letters and digits, that can't be part of the literal, make it INVALID,
+// so 12abc is an error rather than a number followed by an identifier.
 func (l *Lexer) readNumber() (tok token.Token) {
+	pos := l.currentPos
 	var buf strings.Builder
-	for unicode.IsDigit(l.currentRune) {
+	base := 10
+	if l.currentRune == '0' {
+		switch l.nextRune {
+		case 'x', 'X':
+			base = 16
+		case 'o', 'O':
+			base = 8
+		case 'b', 'B':
+			base = 2
+		}
+	}
+	if base != 10 {
+		buf.WriteRune(l.currentRune)
+		l.readRune()
 		buf.WriteRune(l.currentRune)
 		l.readRune()
 	}
-	tok.Literal = buf.String()
 	tok.Type = token.INT
+	reason := l.readDigits(&buf, base)
+	// dot without digits after it is a member access, e.g. 5.x
+	if base == 10 && l.currentRune == '.' && isDecimalDigit(l.nextRune) {
+		buf.WriteRune(l.currentRune)
+		l.readRune()
+		if fracReason := l.readDigits(&buf, base); reason == "" {
+			reason = fracReason
+		}
+		tok.Type = token.FLOAT
+	}
+	tok.Literal = buf.String()
+	if reason != "" {
+		return l.errorToken(pos, tok.Literal, diag.MalformedNumber,
+			fmt.Sprintf("malformed %s literal %q: %s", baseNames[base], tok.Literal, reason))
+	}
 	return tok
 }
 
+var baseNames = map[int]string{2: "binary", 8: "octal", 10: "decimal", 16: "hexadecimal"}
+
+// readDigits reads digits of the base with underscores between them.
+// It also consumes letters and digits, that are not valid in the base,
+// and returns the reason, why the literal is malformed, if it is.
+func (l *Lexer) readDigits(buf *strings.Builder, base int) (reason string) {
+	digits := 0
+	prevUnderscore := false
+	for isLetter(l.currentRune) || unicode.IsDigit(l.currentRune) {
+		r := l.currentRune
+		switch {
+		case r == '_':
+			if (digits == 0 || prevUnderscore) && reason == "" {
+				reason = "'_' must separate successive digits"
+			}
+		case digitValue(r) < base:
+			digits++
+		case reason == "":
+			reason = fmt.Sprintf("invalid digit %q", r)
+		}
+		prevUnderscore = r == '_'
+		buf.WriteRune(r)
+		l.readRune()
+	}
+	switch {
+	case reason != "":
+		return reason
+	case digits == 0:
+		return "no digits"
+	case prevUnderscore:
+		return "'_' must separate successive digits"
+	}
+	return ""
+}
+
+// digitValue returns value of ASCII digit or letter digit, or 36 for other runes.
+func digitValue(r rune) int {
+	switch {
+	case isDecimalDigit(r):
+		return int(r - '0')
+	case 'a' <= r && r <= 'z':
+		return int(r-'a') + 10
+	case 'A' <= r && r <= 'Z':
+		return int(r-'A') + 10
+	}
+	return 36
+}
+
+// readString reads string literal from the opening quote up to the closing one,
+// which is left for the caller to skip.
 func (l *Lexer) readString() (tok token.Token) {
+	pos := l.currentPos
+	l.readRune() // skip opening quote
 	var buf strings.Builder
-	for l.currentRune != '"' && l.currentRune != 0 {
-		buf.WriteRune(l.currentRune)
+	invalidRaw := ""
+	for l.currentRune != '"' && l.currentRune != eof {
+		if l.currentRaw != "" {
+			if invalidRaw == "" {
+				invalidRaw = l.currentRaw
+			}
+			buf.WriteString(l.currentRaw)
+		} else {
+			buf.WriteRune(l.currentRune)
+		}
 		l.readRune()
 	}
+	text := `"` + buf.String()
+	switch {
+	case l.currentRune == eof:
+		return l.errorToken(pos, text, diag.UnterminatedString, "unterminated string literal")
+	case invalidRaw != "":
+		return l.errorToken(pos, text+`"`, diag.InvalidEncoding, fmt.Sprintf("invalid UTF-8 encoding %q in string literal", invalidRaw))
+	}
 	tok.Literal = buf.String()
 	tok.Type = token.STRING
 	return tok
@@ -179,6 +435,24 @@ func lookupIdentType(ident string) token.Type {
 		return token.FALSE
 	case "return":
 		return token.RETURN
+	case "struct":
+		return token.STRUCT
+	case "throw":
+		return token.THROW
+	case "try":
+		return token.TRY
+	case "catch":
+		return token.CATCH
+	case "finally":
+		return token.FINALLY
+	case "yield":
+		return token.YIELD
+	case "for":
+		return token.FOR
+	case "in":
+		return token.IN
+	case "spawn":
+		return token.SPAWN
 	default:
 		return token.IDENT
 	}
@@ -187,3 +461,7 @@ func lookupIdentType(ident string) token.Type {
 func isLetter(r rune) bool {
 	return unicode.IsLetter(r) || r == '_'
 }
+
+func isDecimalDigit(r rune) bool {
+	return '0' <= r && r <= '9'
+}
@@ -1,8 +1,13 @@
 package lexer
 
 import (
+	"errors"
+	"io"
+	"strings"
 	"testing"
+	"testing/iotest"
 
+	"github.com/pechorka/plang/diag"
 	"github.com/pechorka/plang/token"
 )
 
@@ -143,6 +148,192 @@ func TestNext_full(t *testing.T) {
 	testLexer(t, input, tests)
 }
 
+func TestNext_struct(t *testing.T) {
+	input := `struct Point { x, y }
+	p.x;`
+	tests := []lexerResult{
+		{token.STRUCT, "struct"},
+		{token.IDENT, "Point"},
+		{token.LBRACE, "{"},
+		{token.IDENT, "x"},
+		{token.COMMA, ","},
+		{token.IDENT, "y"},
+		{token.RBRACE, "}"},
+		{token.IDENT, "p"},
+		{token.DOT, "."},
+		{token.IDENT, "x"},
+		{token.SEMICOLON, ";"},
+		{token.EOF, ""},
+	}
+
+	testLexer(t, input, tests)
+}
+
+func TestNext_generators(t *testing.T) {
+	input := `for (x in gen) { yield x }`
+	tests := []lexerResult{
+		{token.FOR, "for"},
+		{token.LPAREN, "("},
+		{token.IDENT, "x"},
+		{token.IN, "in"},
+		{token.IDENT, "gen"},
+		{token.RPAREN, ")"},
+		{token.LBRACE, "{"},
+		{token.YIELD, "yield"},
+		{token.IDENT, "x"},
+		{token.RBRACE, "}"},
+		{token.EOF, ""},
+	}
+
+	testLexer(t, input, tests)
+}
+
+func TestNext_float(t *testing.T) {
+	input := `3.14 + 1. 5.x math.pi`
+	tests := []lexerResult{
+		{token.FLOAT, "3.14"},
+		{token.PLUS, "+"},
+		{token.INT, "1"},
+		{token.DOT, "."},
+		{token.INT, "5"},
+		{token.DOT, "."},
+		{token.IDENT, "x"},
+		{token.IDENT, "math"},
+		{token.DOT, "."},
+		{token.IDENT, "pi"},
+		{token.EOF, ""},
+	}
+
+	testLexer(t, input, tests)
+}
+
+func TestNext_numbers(t *testing.T) {
+	input := `x1 utf8 _2 1_000 0x1F 0o17 0b10 1_0.5 0.5`
+	tests := []lexerResult{
+		{token.IDENT, "x1"},
+		{token.IDENT, "utf8"},
+		{token.IDENT, "_2"},
+		{token.INT, "1_000"},
+		{token.INT, "0x1F"},
+		{token.INT, "0o17"},
+		{token.INT, "0b10"},
+		{token.FLOAT, "1_0.5"},
+		{token.FLOAT, "0.5"},
+		{token.EOF, ""},
+	}
+
+	testLexer(t, input, tests)
+}
+
+func TestNext_malformedNumbers(t *testing.T) {
+	input := `0xZZ + 12abc`
+	tests := []lexerResult{
+		{token.INVALID, "0xZZ"},
+		{token.PLUS, "+"},
+		{token.INVALID, "12abc"},
+		{token.EOF, ""},
+	}
+	l := NewFromString(input)
+	for i, res := range tests {
+		tok := l.Next()
+		if tok.Type != res.expectedType || tok.Literal != res.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong token. expected=%s %q, got=%s %q",
+				i, res.expectedType, res.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+
+	expected := []Error{
+		{Pos: token.Position{Line: 1, Column: 1}, Text: "0xZZ", Code: diag.MalformedNumber, Reason: `malformed hexadecimal literal "0xZZ": invalid digit 'Z'`},
+		{Pos: token.Position{Line: 1, Column: 8}, Text: "12abc", Code: diag.MalformedNumber, Reason: `malformed decimal literal "12abc": invalid digit 'a'`},
+	}
+	errors := l.Errors()
+	if len(errors) != len(expected) {
+		t.Fatalf("wrong number of errors. expected=%d, got=%d (%v)", len(expected), len(errors), errors)
+	}
+	for i, err := range errors {
+		if err != expected[i] {
+			t.Errorf("errors[%d] wrong. expected=%+v, got=%+v", i, expected[i], err)
+		}
+	}
+}
+
+func TestNext_comments(t *testing.T) {
+	input := `// leading comment
+let x = 10 / 2; // trailing comment
+// comment before EOF`
+	tests := []lexerResult{
+		{token.LET, "let"},
+		{token.IDENT, "x"},
+		{token.ASSIGN, "="},
+		{token.INT, "10"},
+		{token.SLASH, "/"},
+		{token.INT, "2"},
+		{token.SEMICOLON, ";"},
+		{token.EOF, ""},
+	}
+
+	testLexer(t, input, tests)
+}
+
+func TestNext_trivia(t *testing.T) {
+	input := "x  // note\n\t\"s\""
+	l := NewFromString(input)
+
+	tok := l.Next()
+	if l.Text() != "x" || len(l.Trivia()) != 0 {
+		t.Fatalf("wrong text or trivia of %s: %q %+v", tok.Type, l.Text(), l.Trivia())
+	}
+
+	tok = l.Next()
+	if tok.Type != token.STRING || tok.Literal != "s" || l.Text() != `"s"` {
+		t.Fatalf("wrong string token: %+v, text %q", tok, l.Text())
+	}
+	expected := []Trivia{
+		{Kind: Whitespace, Text: "  ", Pos: token.Position{Line: 1, Column: 2}},
+		{Kind: Comment, Text: "// note", Pos: token.Position{Line: 1, Column: 4}},
+		{Kind: Whitespace, Text: "\n\t", Pos: token.Position{Line: 1, Column: 11}},
+	}
+	trivia := l.Trivia()
+	if len(trivia) != len(expected) {
+		t.Fatalf("wrong trivia. expected=%+v, got=%+v", expected, trivia)
+	}
+	for i, tr := range trivia {
+		if tr != expected[i] {
+			t.Errorf("trivia[%d] wrong. expected=%+v, got=%+v", i, expected[i], tr)
+		}
+	}
+
+	tok = l.Next()
+	if tok.Type != token.EOF || l.Text() != "" || len(l.Trivia()) != 0 {
+		t.Fatalf("wrong end of input: %+v, text %q, trivia %+v", tok, l.Text(), l.Trivia())
+	}
+}
+
+func TestNext_positions(t *testing.T) {
+	input := `let x = "ы";
+  try { throw x }`
+	expected := []token.Position{
+		{Line: 1, Column: 1},  // let
+		{Line: 1, Column: 5},  // x
+		{Line: 1, Column: 7},  // =
+		{Line: 1, Column: 9},  // "ы"
+		{Line: 1, Column: 12}, // ;
+		{Line: 2, Column: 3},  // try
+		{Line: 2, Column: 7},  // {
+		{Line: 2, Column: 9},  // throw
+		{Line: 2, Column: 15}, // x
+		{Line: 2, Column: 17}, // }
+	}
+	l := NewFromString(input)
+	for i, pos := range expected {
+		tok := l.Next()
+		if tok.Pos != pos {
+			t.Fatalf("tests[%d] - position of %q wrong. expected=%s, got=%s",
+				i, tok.Literal, pos, tok.Pos)
+		}
+	}
+}
+
 func TestNext_invalid(t *testing.T) {
 	input := `@let`
 	l := NewFromString(input)
@@ -153,6 +344,68 @@ func TestNext_invalid(t *testing.T) {
 	}
 }
 
+func TestNext_errors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Error
+	}{
+		{"1 @ 2", Error{Pos: token.Position{Line: 1, Column: 3}, Text: "@", Code: diag.InvalidCharacter, Reason: `unexpected character '@'`}},
+		{"x\x00", Error{Pos: token.Position{Line: 1, Column: 2}, Text: "\x00", Code: diag.InvalidCharacter, Reason: `unexpected character '\x00'`}},
+		{"1 +\n\xff", Error{Pos: token.Position{Line: 2, Column: 1}, Text: "\xff", Code: diag.InvalidEncoding, Reason: `invalid UTF-8 encoding "\xff"`}},
+		{`let s = "abc`, Error{Pos: token.Position{Line: 1, Column: 9}, Text: `"abc`, Code: diag.UnterminatedString, Reason: "unterminated string literal"}},
+		{"\"a\xffb\"", Error{Pos: token.Position{Line: 1, Column: 1}, Text: "\"a\xffb\"", Code: diag.InvalidEncoding, Reason: `invalid UTF-8 encoding "\xff" in string literal`}},
+	}
+
+	for i, tt := range tests {
+		l := NewFromString(tt.input)
+		invalid := 0
+		for tok := l.Next(); tok.Type != token.EOF; tok = l.Next() {
+			if tok.Type == token.INVALID {
+				invalid++
+				if tok.Literal != tt.expected.Text {
+					t.Errorf("test[%d]: wrong literal of invalid token. expected=%q, got=%q", i, tt.expected.Text, tok.Literal)
+				}
+			}
+		}
+		if invalid != 1 {
+			t.Errorf("test[%d]: expected 1 invalid token, got %d", i, invalid)
+		}
+		errors := l.Errors()
+		if len(errors) != 1 {
+			t.Fatalf("test[%d]: expected 1 error, got %v", i, errors)
+		}
+		if errors[0] != tt.expected {
+			t.Errorf("test[%d]: wrong error. expected=%+v, got=%+v", i, tt.expected, errors[0])
+		}
+	}
+}
+
+func TestNext_readError(t *testing.T) {
+	readErr := errors.New("disk failure")
+	l := New(io.MultiReader(strings.NewReader("let x"), iotest.ErrReader(readErr)))
+
+	testTokens := []lexerResult{
+		{token.LET, "let"},
+		{token.IDENT, "x"},
+		{token.INVALID, ""},
+		{token.EOF, ""},
+		{token.EOF, ""},
+	}
+	for i, res := range testTokens {
+		tok := l.Next()
+		if tok.Type != res.expectedType || tok.Literal != res.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong token. expected=%s %q, got=%s %q",
+				i, res.expectedType, res.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+
+	expected := Error{Pos: token.Position{Line: 1, Column: 6}, Code: diag.ReadFailure, Reason: "read error: disk failure"}
+	errs := l.Errors()
+	if len(errs) != 1 || errs[0] != expected {
+		t.Fatalf("wrong errors. expected=[%+v], got=%+v", expected, errs)
+	}
+}
+
 func testLexer(t *testing.T, input string, tests []lexerResult) {
 	t.Helper()
 	l := NewFromString(input)
@@ -4,10 +4,19 @@ import (
 	"bytes"
 	"fmt"
 	"hash/fnv"
+	"io"
+	"math"
+	"math/rand"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/pechorka/plang/ast"
+	"github.com/pechorka/plang/diag"
+	"github.com/pechorka/plang/token"
 )
 
 type Type string
@@ -23,6 +32,15 @@ const (
 	BUILTIN_OBJ      Type = "BUILTIN"
 	ARRAY_OBJ        Type = "ARRAY"
 	HASH_OBJ         Type = "HASH"
+	STRUCT_OBJ       Type = "STRUCT"
+	ITERATOR_OBJ     Type = "ITERATOR"
+	TASK_OBJ         Type = "TASK"
+	CHANNEL_OBJ      Type = "CHANNEL"
+	FLOAT_OBJ        Type = "FLOAT"
+	MODULE_OBJ       Type = "MODULE"
+	REGEX_OBJ        Type = "REGEX"
+	TIME_OBJ         Type = "TIME"
+	DURATION_OBJ     Type = "DURATION"
 )
 
 type Object interface {
@@ -34,6 +52,12 @@ type Hashable interface {
 	HashKey() HashKey
 }
 
+// Iterable is implemented by objects, that can be iterated with for loop
+// and consumed by iterator builtins.
+type Iterable interface {
+	Iter() *Iterator
+}
+
 type HashKey struct {
 	Type  Type
 	Value uint64
@@ -55,6 +79,33 @@ func (i *Integer) HashKey() HashKey {
 	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
 }
 
+type Float struct {
+	Value float64
+}
+
+// Inspect always shows the fractional part,
+// so floats and integers can be told apart.
+func (f *Float) Inspect() string {
+	str := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	if strings.ContainsAny(str, ".eIN") { // fraction, exponent, Inf or NaN
+		return str
+	}
+	return str + ".0"
+}
+
+func (f *Float) Type() Type {
+	return FLOAT_OBJ
+}
+
+// HashKey of integral float is the key of the equal integer,
+// so keys are the same for values, that are equal, e.g. 1 and 1.0, or 0.0 and -0.0.
+func (f *Float) HashKey() HashKey {
+	if f.Value == math.Trunc(f.Value) && f.Value >= -(1<<63) && f.Value < 1<<63 {
+		return (&Integer{Value: int64(f.Value)}).HashKey()
+	}
+	return HashKey{Type: f.Type(), Value: math.Float64bits(f.Value)}
+}
+
 type String struct {
 	Value string
 }
@@ -67,6 +118,19 @@ func (s *String) Type() Type {
 	return STRING_OBJ
 }
 
+// Iter iterates over runes of the string, each rune is a separate string.
+func (s *String) Iter() *Iterator {
+	runes := []rune(s.Value)
+	i := 0
+	return &Iterator{Next: func() (Object, bool) {
+		if i >= len(runes) {
+			return nil, false
+		}
+		i++
+		return &String{Value: string(runes[i-1])}, true
+	}}
+}
+
 func (s *String) HashKey() HashKey {
 	h := fnv.New64a()
 	h.Write([]byte(s.Value))
@@ -118,8 +182,52 @@ func (rv *ReturnValue) Inspect() string {
 	return rv.Value.Inspect()
 }
 
+// Kinds of errors. Kind is available to scripts when an error is caught.
+const (
+	RUNTIME_ERROR  = "RuntimeError"
+	TYPE_ERROR     = "TypeError"
+	NAME_ERROR     = "NameError"
+	ARGUMENT_ERROR = "ArgumentError"
+	THROWN_ERROR   = "ThrownError" // created by throw statement
+	IO_ERROR       = "IOError"
+	// script tried to use a capability, that was not granted by the host
+	PERMISSION_ERROR = "PermissionError"
+	// created by exit builtin to unwind the script. It can't be caught,
+	// Value holds exit code. Errors of this kind are exits only if created by NewExit
+	EXIT = "Exit"
+)
+
 type Error struct {
+	Kind    string
 	Message string
+	Pos     token.Position // where the error happened, if known
+	// where the error happened, if it is not in the source of Pos,
+	// e.g. in the prelude function called at Pos
+	Origin token.Position
+	Value  Object // value passed to throw, nil for runtime errors
+	exit   bool   // set by NewExit only, so scripts can't fake exit with throw
+}
+
+// NewExit returns error, that unwinds the script with the exit code.
+func NewExit(code int64) *Error {
+	return &Error{
+		Kind:    EXIT,
+		Message: "exit " + strconv.FormatInt(code, 10),
+		Value:   &Integer{Value: code},
+		exit:    true,
+	}
+}
+
+// ExitCode returns the code of exit, if the error was created by NewExit.
+func (e *Error) ExitCode() (int, bool) {
+	if !e.exit {
+		return 0, false
+	}
+	code, ok := e.Value.(*Integer)
+	if !ok {
+		return 0, false
+	}
+	return int(code.Value), true
 }
 
 func (e *Error) Type() Type {
@@ -129,6 +237,24 @@ func (e *Error) Inspect() string {
 	return "ERROR: " + e.Message
 }
 
+// Diagnostic describes uncaught error. Its code is the kind of the error.
+func (e *Error) Diagnostic() diag.Diagnostic {
+	d := diag.Diagnostic{
+		Severity: diag.Error,
+		Code:     diag.Code(e.Kind),
+		Message:  e.Message,
+		Primary:  diag.Span{Start: e.Pos, End: e.Pos},
+	}
+	if e.Origin.IsValid() {
+		origin := e.Origin.String()
+		if e.Origin.Source != "" {
+			origin = e.Origin.Source + ":" + origin
+		}
+		d.Hints = append(d.Hints, "the error happened at "+origin)
+	}
+	return d
+}
+
 func NewEnvironment() *Environment {
 	s := make(map[string]Object)
 	return &Environment{store: s}
@@ -140,27 +266,49 @@ func NewEnclosedEnvironment(outer *Environment) *Environment {
 	return env
 }
 
+// NewLoopEnvironment returns environment, that binds only the name to the value.
+// Other names are set in the outer environment, so variable of the loop
+// is visible only in its body, while lets of the body change the enclosing scope.
+func NewLoopEnvironment(outer *Environment, name string, val Object) *Environment {
+	env := NewEnclosedEnvironment(outer)
+	env.bound = name
+	env.store[name] = val
+	return env
+}
+
+// Environment is safe for concurrent use,
+// because closures can be shared between spawned tasks.
 type Environment struct {
+	mu    sync.RWMutex
 	store map[string]Object
 	outer *Environment
+	bound string // the only name of loop environment, see NewLoopEnvironment
 }
 
 func (e *Environment) Get(name string) (Object, bool) {
+	e.mu.RLock()
 	obj, ok := e.store[name]
+	e.mu.RUnlock()
 	if !ok && e.outer != nil {
 		obj, ok = e.outer.Get(name)
 	}
 	return obj, ok
 }
 func (e *Environment) Set(name string, val Object) Object {
+	if e.bound != "" && name != e.bound {
+		return e.outer.Set(name, val)
+	}
+	e.mu.Lock()
 	e.store[name] = val
+	e.mu.Unlock()
 	return val
 }
 
 type Function struct {
-	Parameters []*ast.Identifier
-	Body       *ast.BlockStatement
-	Env        *Environment
+	Parameters  []*ast.Identifier
+	Body        *ast.BlockStatement
+	Env         *Environment
+	IsGenerator bool // calling generator function returns Iterator
 }
 
 func (f *Function) Type() Type {
@@ -182,17 +330,58 @@ func (f *Function) Inspect() string {
 	return out.String()
 }
 
-type BuiltinFunction func(args ...Object) Object
+// Interpreter is implemented by the evaluator and passed to builtins,
+// so they can call back into plang, e.g. to apply a function passed as an argument.
+type Interpreter interface {
+	Apply(fn Object, args ...Object) Object
+	// Output is where builtins like print write to.
+	Output() io.Writer
+	// Rand is the source of random builtins. It is safe for concurrent use.
+	Rand() *rand.Rand
+}
+
+type BuiltinFunction func(in Interpreter, args ...Object) Object
 
+// Builtin is a function implemented in Go.
+// The evaluator checks number of arguments against Params before calling Fn.
 type Builtin struct {
-	Fn BuiltinFunction
+	// Name is set, when builtin is registered, e.g. "len" or "math.sqrt".
+	Name string
+	// Params are names of parameters. Names of optional parameters end with "?"
+	// and name of the variadic last parameter ends with "...".
+	Params []string
+	Doc    string
+	Fn     BuiltinFunction
 }
 
 func (b *Builtin) Type() Type {
 	return BUILTIN_OBJ
 }
 func (b *Builtin) Inspect() string {
-	return "builtin function"
+	if b.Name == "" {
+		return "builtin function"
+	}
+	return "builtin " + b.Signature()
+}
+
+// Signature returns name with parameters, e.g. "split(str, sep)".
+func (b *Builtin) Signature() string {
+	return b.Name + "(" + strings.Join(b.Params, ", ") + ")"
+}
+
+// Arity returns minimal and maximal number of arguments.
+// Maximum is -1 for variadic builtins.
+func (b *Builtin) Arity() (min, max int) {
+	for _, p := range b.Params {
+		switch {
+		case strings.HasSuffix(p, "..."):
+			return min, -1
+		case strings.HasSuffix(p, "?"):
+		default:
+			min++
+		}
+	}
+	return min, len(b.Params)
 }
 
 type Array struct {
@@ -213,6 +402,17 @@ func (ao *Array) Inspect() string {
 	return out.String()
 }
 
+func (ao *Array) Iter() *Iterator {
+	i := 0
+	return &Iterator{Next: func() (Object, bool) {
+		if i >= len(ao.Elements) {
+			return nil, false
+		}
+		i++
+		return ao.Elements[i-1], true
+	}}
+}
+
 type HashPair struct {
 	Key   Object
 	Value Object
@@ -237,3 +437,240 @@ func (h *Hash) Inspect() string {
 	out.WriteString("}")
 	return out.String()
 }
+
+// Iter iterates over [key, value] arrays.
+// Pairs are ordered by keys, so iteration order is stable.
+func (h *Hash) Iter() *Iterator {
+	pairs := h.SortedPairs()
+	i := 0
+	return &Iterator{Next: func() (Object, bool) {
+		if i >= len(pairs) {
+			return nil, false
+		}
+		i++
+		pair := pairs[i-1]
+		return &Array{Elements: []Object{pair.Key, pair.Value}}, true
+	}}
+}
+
+// SortedPairs returns pairs ordered by type and then by inspected value of the key.
+func (h *Hash) SortedPairs() []HashPair {
+	pairs := make([]HashPair, 0, len(h.Pairs))
+	for _, pair := range h.Pairs {
+		pairs = append(pairs, pair)
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		ki, kj := pairs[i].Key, pairs[j].Key
+		if ki.Type() != kj.Type() {
+			return ki.Type() < kj.Type()
+		}
+		if ii, ok := ki.(*Integer); ok {
+			return ii.Value < kj.(*Integer).Value
+		}
+		return ki.Inspect() < kj.Inspect()
+	})
+	return pairs
+}
+
+// Iterator is a lazy sequence of values.
+// Next returns false, when there are no more values.
+type Iterator struct {
+	Next func() (Object, bool)
+}
+
+func (it *Iterator) Type() Type {
+	return ITERATOR_OBJ
+}
+
+func (it *Iterator) Inspect() string {
+	return "iterator"
+}
+
+func (it *Iterator) Iter() *Iterator {
+	return it
+}
+
+// StructType is the value bound to the name of a struct declaration.
+// Calling it constructs a new StructInstance.
+type StructType struct {
+	Name    string
+	Fields  []string
+	Methods map[string]*Function
+}
+
+func (st *StructType) Type() Type {
+	return STRUCT_OBJ
+}
+
+func (st *StructType) Inspect() string {
+	var out bytes.Buffer
+	out.WriteString("struct ")
+	out.WriteString(st.Name)
+	out.WriteString(" { ")
+	out.WriteString(strings.Join(st.Fields, ", "))
+	out.WriteString(" }")
+	return out.String()
+}
+
+type StructInstance struct {
+	Struct *StructType
+	Fields map[string]Object
+}
+
+// Type of a struct instance is "struct " and the name of its struct,
+// so every struct declaration introduces a distinct type,
+// which can't be mistaken for builtin one, e.g. struct named STRING.
+func (si *StructInstance) Type() Type {
+	return Type("struct " + si.Struct.Name)
+}
+
+func (si *StructInstance) Inspect() string {
+	var out bytes.Buffer
+	out.WriteString(si.Struct.Name)
+	out.WriteString("{")
+	for i, f := range si.Struct.Fields {
+		if i > 0 {
+			out.WriteString(", ")
+		}
+		out.WriteString(f)
+		out.WriteString(": ")
+		out.WriteString(si.Fields[f].Inspect())
+	}
+	out.WriteString("}")
+	return out.String()
+}
+
+// Task is a function call running on its own goroutine.
+type Task struct {
+	done   chan struct{}
+	result Object
+}
+
+func NewTask() *Task {
+	return &Task{done: make(chan struct{})}
+}
+
+func (t *Task) Type() Type {
+	return TASK_OBJ
+}
+
+func (t *Task) Inspect() string {
+	return "task"
+}
+
+// Finish stores result of the task and wakes up everyone waiting for it.
+// It must be called exactly once.
+func (t *Task) Finish(result Object) {
+	t.result = result
+	close(t.done)
+}
+
+// Wait blocks until the task is finished and returns its result.
+func (t *Task) Wait() Object {
+	<-t.done
+	return t.result
+}
+
+type Channel struct {
+	Chan chan Object
+
+	mu     sync.Mutex
+	closed bool
+}
+
+func NewChannel(capacity int) *Channel {
+	return &Channel{Chan: make(chan Object, capacity)}
+}
+
+func (c *Channel) Type() Type {
+	return CHANNEL_OBJ
+}
+
+func (c *Channel) Inspect() string {
+	return "channel"
+}
+
+// Close closes the channel and reports whether it was open.
+func (c *Channel) Close() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return false
+	}
+	c.closed = true
+	close(c.Chan)
+	return true
+}
+
+// Send sends val to the channel and reports whether the channel was open.
+func (c *Channel) Send(val Object) (sent bool) {
+	defer func() {
+		// send on closed channel panics, there is no way to check it upfront
+		// without holding lock for the whole duration of blocking send.
+		if recover() != nil {
+			sent = false
+		}
+	}()
+	c.Chan <- val
+	return true
+}
+
+// Iter receives values until the channel is closed.
+func (c *Channel) Iter() *Iterator {
+	return &Iterator{Next: func() (Object, bool) {
+		val, ok := <-c.Chan
+		return val, ok
+	}}
+}
+
+// Module is a namespace of builtins and constants, e.g. math.
+// Members are accessed with dot: math.sqrt(2).
+type Module struct {
+	Name    string
+	Members map[string]Object
+}
+
+func (m *Module) Type() Type {
+	return MODULE_OBJ
+}
+
+func (m *Module) Inspect() string {
+	return "module " + m.Name
+}
+
+type Regex struct {
+	Value *regexp.Regexp
+}
+
+func (r *Regex) Type() Type {
+	return REGEX_OBJ
+}
+
+func (r *Regex) Inspect() string {
+	return "regex(" + strconv.Quote(r.Value.String()) + ")"
+}
+
+// Time is a point in time with location, in which it is shown.
+type Time struct {
+	Value time.Time
+}
+
+func (t *Time) Type() Type {
+	return TIME_OBJ
+}
+
+func (t *Time) Inspect() string {
+	return t.Value.Format(time.RFC3339Nano)
+}
+
+type Duration struct {
+	Value time.Duration
+}
+
+func (d *Duration) Type() Type {
+	return DURATION_OBJ
+}
+
+func (d *Duration) Inspect() string {
+	return d.Value.String()
+}
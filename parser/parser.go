@@ -3,8 +3,10 @@ package parser
 import (
 	"fmt"
 	"strconv"
+	"strings"
 
 	"github.com/pechorka/plang/ast"
+	"github.com/pechorka/plang/diag"
 	"github.com/pechorka/plang/lexer"
 	"github.com/pechorka/plang/token"
 )
@@ -33,22 +35,46 @@ var precedences = map[token.Type]int{
 	token.ASTERISK: PRODUCT,
 	token.LPAREN:   CALL,
 	token.LBRACKET: INDEX,
+	token.DOT:      INDEX,
 }
 
 type (
 	prefixParseFn func() ast.Expression
 	infixParseFn  func(ast.Expression) ast.Expression
 )
+
+// Lexer is the source of tokens. It is usually *lexer.Lexer.
+type Lexer interface {
+	Next() token.Token
+	// Errors returns errors of the tokens read so far,
+	// the error of INVALID token is the last one, when it is returned.
+	Errors() []lexer.Error
+}
+
 type Parser struct {
-	l              *lexer.Lexer
+	l              Lexer
 	curToken       token.Token
 	nextToken      token.Token
-	errors         []string
+	diagnostics    []diag.Diagnostic
 	prefixParseFns map[token.Type]prefixParseFn
 	infixParseFns  map[token.Type]infixParseFn
+
+	fnDepth  int  // number of fn bodies, that are being parsed
+	sawYield bool // current fn body contains yield
+
+	// panicking is set by the first error of a statement. Errors are not reported,
+	// until the parser synchronizes at the next statement, because they are
+	// most likely caused by the first one.
+	panicking  bool
+	annotate   bool // the last error is from isNextToken, the caller may explain it with hint
+	braceDepth int  // number of unclosed { up to the current token
+	blockDepth int  // brace depth of statements of the current block
+
+	read   int // number of tokens read from the lexer
+	onNode func(n ast.Node, first, last int)
 }
 
-func New(l *lexer.Lexer) *Parser {
+func New(l Lexer) *Parser {
 	p := &Parser{
 		l:              l,
 		prefixParseFns: make(map[token.Type]prefixParseFn),
@@ -57,6 +83,8 @@ func New(l *lexer.Lexer) *Parser {
 
 	p.registerPrefix(token.IDENT, p.parseIdentifier)
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)
+	p.registerPrefix(token.FLOAT, p.parseFloatLiteral)
+	p.registerPrefix(token.INVALID, p.parseInvalid)
 	p.registerPrefix(token.STRING, p.parseStringLiteral)
 	p.registerPrefix(token.TRUE, p.parseBooleanLiteral)
 	p.registerPrefix(token.FALSE, p.parseBooleanLiteral)
@@ -67,6 +95,9 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.FUNCTION, p.parseFnExpression)
 	p.registerPrefix(token.LBRACKET, p.parseArrayExpression)
 	p.registerPrefix(token.LBRACE, p.parseHashExpression)
+	p.registerPrefix(token.TRY, p.parseTryExpression)
+	p.registerPrefix(token.FOR, p.parseForExpression)
+	p.registerPrefix(token.SPAWN, p.parseSpawnExpression)
 
 	p.registerInfix(token.PLUS, p.parseInfixExpression)
 	p.registerInfix(token.MINUS, p.parseInfixExpression)
@@ -78,6 +109,7 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.GT, p.parseInfixExpression)
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
 	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
+	p.registerInfix(token.DOT, p.parseMemberExpression)
 
 	// fill cur and next token
 	p.readToken()
@@ -86,10 +118,16 @@ func New(l *lexer.Lexer) *Parser {
 	return p
 }
 
+// Parse parses the whole program. After a syntax error it skips to the next statement
+// and continues, so Errors contains all independent errors of the program.
 func (p *Parser) Parse() *ast.Program {
 	var prog ast.Program
 	for p.curToken.Type != token.EOF {
 		stmt := p.parseStatement()
+		if p.panicking {
+			p.synchronize()
+			continue
+		}
 		if stmt != nil {
 			prog.Statements = append(prog.Statements, stmt)
 		}
@@ -98,21 +136,123 @@ func (p *Parser) Parse() *ast.Program {
 	return &prog
 }
 
+// Errors returns messages of Diagnostics. Hints follow the message of their diagnostic.
 func (p *Parser) Errors() []string {
-	return p.errors
+	var msgs []string
+	for _, d := range p.diagnostics {
+		msgs = append(msgs, d.Message)
+		msgs = append(msgs, d.Hints...)
+	}
+	return msgs
+}
+
+// Diagnostics returns syntax errors of the program, including errors of the lexer.
+func (p *Parser) Diagnostics() []diag.Diagnostic {
+	return p.diagnostics
 }
 
 func (p *Parser) readToken() {
+	p.read++
 	p.curToken = p.nextToken
+	switch p.curToken.Type {
+	case token.LBRACE:
+		p.braceDepth++
+	case token.RBRACE:
+		if p.braceDepth > 0 { // stray } is reported by the parser
+			p.braceDepth--
+		}
+	}
 	p.nextToken = p.l.Next()
+	if p.nextToken.Type == token.INVALID {
+		p.appendLexerError()
+	}
+}
+
+// appendLexerError reports the error of the lexer about the INVALID token, that was just read.
+// It is reported once, as soon as the token is read, wherever it occurs,
+// even when the parser is panicking, because it doesn't depend on other errors.
+func (p *Parser) appendLexerError() {
+	errs := p.l.Errors()
+	if len(errs) == 0 {
+		p.diagnostics = append(p.diagnostics, diag.Diagnostic{
+			Severity: diag.Error,
+			Code:     diag.InvalidSyntax,
+			Message:  fmt.Sprintf("invalid token %q", p.nextToken.Literal),
+			Primary:  tokenSpan(p.nextToken),
+		})
+		return
+	}
+	p.diagnostics = append(p.diagnostics, errs[len(errs)-1].Diagnostic())
+}
+
+// synchronize skips tokens of the statement with the error up to the start of the next one:
+// the token after semicolon, a keyword, that starts statement, or } closing the current block.
+// The parser stays panicking, if the input ends, because the rest of errors are follow-on ones.
+func (p *Parser) synchronize() {
+	p.annotate = false
+	for p.curToken.Type != token.EOF {
+		switch {
+		case p.curToken.Type == token.RBRACE && p.braceDepth < p.blockDepth:
+			p.panicking = false
+			return
+		case p.curToken.Type == token.SEMICOLON && p.braceDepth == p.blockDepth:
+			p.readToken()
+			p.panicking = false
+			return
+		}
+		p.readToken()
+		if p.braceDepth == p.blockDepth && statementKeywords[p.curToken.Type] {
+			p.panicking = false
+			return
+		}
+	}
+}
+
+var statementKeywords = map[token.Type]bool{
+	token.LET:    true,
+	token.RETURN: true,
+	token.STRUCT: true,
+	token.THROW:  true,
+	token.YIELD:  true,
+}
+
+// OnNode registers fn, that is called for every parsed statement, expression and block
+// with indices of its first and last tokens in the input, e.g. 0 is the first token.
+// Nodes are reported after their children. It is used to build concrete syntax tree.
+func (p *Parser) OnNode(fn func(n ast.Node, first, last int)) {
+	p.onNode = fn
+}
+
+// curIndex returns index of the current token in the input.
+func (p *Parser) curIndex() int {
+	return p.read - 2 // the next token is read too
+}
+
+func (p *Parser) trackNode(n ast.Node, first int) {
+	if p.onNode != nil && n != nil {
+		p.onNode(n, first, p.curIndex())
+	}
 }
 
 func (p *Parser) parseStatement() ast.Statement {
+	first := p.curIndex()
+	stmt := p.parseStatementKind()
+	p.trackNode(stmt, first)
+	return stmt
+}
+
+func (p *Parser) parseStatementKind() ast.Statement {
 	switch p.curToken.Type {
 	case token.LET:
 		return p.parseLetStatement()
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.STRUCT:
+		return p.parseStructStatement()
+	case token.THROW:
+		return p.parseThrowStatement()
+	case token.YIELD:
+		return p.parseYieldStatement()
 	}
 
 	return p.parseExpressionStatement()
@@ -139,8 +279,13 @@ func (p *Parser) parseLetStatement() ast.Statement {
 	p.readToken() // consume =
 
 	stmt.Value = p.parseExpression(LOWEST)
+	if stmt.Value == nil {
+		return nil
+	}
 
-	p.skipUntilSemicolon()
+	if p.nextToken.Type == token.SEMICOLON { // semicolon is optional
+		p.readToken()
+	}
 
 	return &stmt
 }
@@ -153,12 +298,151 @@ func (p *Parser) parseReturnStatement() ast.Statement {
 	p.readToken() // consume return
 
 	stmt.Value = p.parseExpression(LOWEST)
+	if stmt.Value == nil {
+		return nil
+	}
+
+	if p.nextToken.Type == token.SEMICOLON { // semicolon is optional
+		p.readToken()
+	}
+
+	return &stmt
+}
+
+func (p *Parser) parseThrowStatement() ast.Statement {
+	stmt := ast.ThrowStatement{
+		Token: p.curToken,
+	}
+
+	p.readToken() // consume throw
+
+	stmt.Value = p.parseExpression(LOWEST)
+	if stmt.Value == nil {
+		return nil
+	}
 
-	p.skipUntilSemicolon()
+	if p.nextToken.Type == token.SEMICOLON { // semicolon is optional
+		p.readToken()
+	}
 
 	return &stmt
 }
 
+func (p *Parser) parseYieldStatement() ast.Statement {
+	stmt := ast.YieldStatement{
+		Token: p.curToken,
+	}
+
+	if p.fnDepth == 0 {
+		p.appendErrorf("yield outside of fn")
+		return nil
+	}
+	p.sawYield = true
+
+	p.readToken() // consume yield
+
+	stmt.Value = p.parseExpression(LOWEST)
+	if stmt.Value == nil {
+		return nil
+	}
+
+	if p.nextToken.Type == token.SEMICOLON { // semicolon is optional
+		p.readToken()
+	}
+
+	return &stmt
+}
+
+func (p *Parser) parseStructStatement() ast.Statement {
+	stmt := ast.StructStatement{
+		Token: p.curToken,
+	}
+
+	if !p.isNextToken(token.IDENT) {
+		return nil
+	}
+
+	stmt.Name = &ast.Identifier{
+		Token: p.curToken,
+		Value: p.curToken.Literal,
+	}
+
+	if !p.isNextToken(token.LBRACE) {
+		return nil
+	}
+
+	declared := make(map[string]bool)
+	for p.nextToken.Type != token.RBRACE {
+		p.readToken()
+		switch p.curToken.Type {
+		case token.COMMA, token.SEMICOLON: // separators are optional
+			continue
+		case token.IDENT:
+			field := p.parseIdentifier().(*ast.Identifier)
+			if declared[field.Value] {
+				p.appendErrorf("duplicate member %q in struct %s", field.Value, stmt.Name.Value)
+				return nil
+			}
+			declared[field.Value] = true
+			stmt.Fields = append(stmt.Fields, field)
+		case token.FUNCTION:
+			method := p.parseStructMethod()
+			if method == nil {
+				return nil
+			}
+			if declared[method.Name.Value] {
+				p.appendErrorf("duplicate member %q in struct %s", method.Name.Value, stmt.Name.Value)
+				return nil
+			}
+			declared[method.Name.Value] = true
+			stmt.Methods = append(stmt.Methods, method)
+		default:
+			p.appendErrorf("unexpected %q in struct %s", p.curToken.Type, stmt.Name.Value)
+			return nil
+		}
+	}
+
+	p.readToken() // move to }
+
+	if p.nextToken.Type == token.SEMICOLON { // semicolon is optional
+		p.readToken()
+	}
+
+	return &stmt
+}
+
+func (p *Parser) parseStructMethod() *ast.StructMethod {
+	fnExpr := ast.FnExpression{
+		Token: p.curToken,
+	}
+
+	if !p.isNextToken(token.IDENT) {
+		p.appendErrorf("invalid method: no name after fn")
+		return nil
+	}
+
+	name := p.parseIdentifier().(*ast.Identifier)
+
+	if !p.isNextToken(token.LPAREN) {
+		p.appendErrorf("invalid method: no ( after method name")
+		return nil
+	}
+
+	fnExpr.Params = p.parseFnParams()
+
+	if !p.isNextToken(token.LBRACE) {
+		p.appendErrorf("invalid method: no { after param list")
+		return nil
+	}
+
+	fnExpr.Body, fnExpr.IsGenerator = p.parseFnBody()
+
+	return &ast.StructMethod{
+		Name: name,
+		Fn:   &fnExpr,
+	}
+}
+
 func (p *Parser) parseExpressionStatement() ast.Statement {
 	exp := p.parseExpression(LOWEST)
 	if exp == nil {
@@ -178,12 +462,19 @@ func (p *Parser) parseExpressionStatement() ast.Statement {
 }
 
 func (p *Parser) parseExpression(precedence int) ast.Expression {
+	first := p.curIndex()
 	prefix := p.prefixParseFns[p.curToken.Type]
 	if prefix == nil {
-		p.appendErrorf("no prefix func for %q token type", p.curToken.Type)
+		p.report(diag.Diagnostic{
+			Severity: diag.Error,
+			Code:     diag.UnexpectedToken,
+			Message:  fmt.Sprintf("no prefix func for %q token type", p.curToken.Type),
+			Primary:  tokenSpan(p.curToken),
+		})
 		return nil
 	}
 	leftExp := prefix()
+	p.trackNode(leftExp, first)
 
 	for p.nextToken.Type != token.SEMICOLON && precedence < p.nextTokenPrecedence() {
 		infix := p.infixParseFns[p.nextToken.Type]
@@ -192,6 +483,7 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 		}
 		p.readToken()
 		leftExp = infix(leftExp)
+		p.trackNode(leftExp, first)
 	}
 
 	return leftExp
@@ -205,7 +497,23 @@ func (p *Parser) parseIdentifier() ast.Expression {
 }
 
 func (p *Parser) parseIntegerLiteral() ast.Expression {
-	val, err := strconv.ParseInt(p.curToken.Literal, 10, 64)
+	// the lexer checked, that prefix, digits and underscores are well-formed
+	lit := strings.ReplaceAll(p.curToken.Literal, "_", "")
+	base := 10
+	if len(lit) > 2 && lit[0] == '0' {
+		switch lit[1] {
+		case 'x', 'X':
+			base = 16
+		case 'o', 'O':
+			base = 8
+		case 'b', 'B':
+			base = 2
+		}
+		if base != 10 {
+			lit = lit[2:]
+		}
+	}
+	val, err := strconv.ParseInt(lit, base, 64)
 	if err != nil {
 		p.appendErrorf("cant parse %q as 64-bit integer", p.curToken.Literal)
 		return nil
@@ -216,6 +524,24 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	}
 }
 
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	val, err := strconv.ParseFloat(strings.ReplaceAll(p.curToken.Literal, "_", ""), 64)
+	if err != nil {
+		p.appendErrorf("cant parse %q as 64-bit float", p.curToken.Literal)
+		return nil
+	}
+	return &ast.FloatLiteral{
+		Token: p.curToken,
+		Value: val,
+	}
+}
+
+// parseInvalid fails to parse the invalid token. Its error is reported, when it is read.
+func (p *Parser) parseInvalid() ast.Expression {
+	p.panicking = true
+	return nil
+}
+
 func (p *Parser) parseStringLiteral() ast.Expression {
 	return &ast.StringLiteral{
 		Token: p.curToken,
@@ -309,23 +635,156 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	return &ifExp
 }
 
+func (p *Parser) parseTryExpression() ast.Expression {
+	tryExp := ast.TryExpression{
+		Token: p.curToken,
+	}
+
+	if !p.isNextToken(token.LBRACE) {
+		p.appendErrorf("invalid try expression: no { after try")
+		return nil
+	}
+
+	tryExp.Block = p.parseBlockStatement()
+
+	if p.nextToken.Type == token.CATCH {
+		p.readToken()
+		if !p.isNextToken(token.LPAREN) {
+			p.appendErrorf("invalid try expression: no ( after catch")
+			return nil
+		}
+		if !p.isNextToken(token.IDENT) {
+			p.appendErrorf("invalid try expression: catch expects a variable name")
+			return nil
+		}
+		tryExp.CatchParam = p.parseIdentifier().(*ast.Identifier)
+		if !p.isNextToken(token.RPAREN) {
+			p.appendErrorf("invalid try expression: no ) after catch variable")
+			return nil
+		}
+		if !p.isNextToken(token.LBRACE) {
+			p.appendErrorf("invalid try expression: no { after catch")
+			return nil
+		}
+		tryExp.Catch = p.parseBlockStatement()
+	}
+
+	if p.nextToken.Type == token.FINALLY {
+		p.readToken()
+		if !p.isNextToken(token.LBRACE) {
+			p.appendErrorf("invalid try expression: no { after finally")
+			return nil
+		}
+		tryExp.Finally = p.parseBlockStatement()
+	}
+
+	if tryExp.Catch == nil && tryExp.Finally == nil {
+		p.appendErrorf("invalid try expression: expected catch or finally")
+		return nil
+	}
+
+	return &tryExp
+}
+
+func (p *Parser) parseForExpression() ast.Expression {
+	forExp := ast.ForExpression{
+		Token: p.curToken,
+	}
+
+	if !p.isNextToken(token.LPAREN) {
+		p.appendErrorf("invalid for expression: no ( after for")
+		return nil
+	}
+
+	if !p.isNextToken(token.IDENT) {
+		p.appendErrorf("invalid for expression: expected loop variable")
+		return nil
+	}
+
+	forExp.Variable = p.parseIdentifier().(*ast.Identifier)
+
+	if !p.isNextToken(token.IN) {
+		p.appendErrorf("invalid for expression: no in after loop variable")
+		return nil
+	}
+
+	p.readToken() // consume in
+
+	forExp.Iterable = p.parseExpression(LOWEST)
+	if forExp.Iterable == nil {
+		return nil
+	}
+
+	if !p.isNextToken(token.RPAREN) {
+		p.appendErrorf("invalid for expression: no ) after iterable")
+		return nil
+	}
+
+	if !p.isNextToken(token.LBRACE) {
+		p.appendErrorf("invalid for expression: no { after )")
+		return nil
+	}
+
+	forExp.Body = p.parseBlockStatement()
+
+	return &forExp
+}
+
+func (p *Parser) parseSpawnExpression() ast.Expression {
+	spawnExp := ast.SpawnExpression{
+		Token: p.curToken,
+	}
+
+	p.readToken() // consume spawn
+
+	spawnExp.Call = p.parseExpression(PREFIX)
+	if spawnExp.Call == nil {
+		return nil
+	}
+
+	return &spawnExp
+}
+
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	blockStmt := ast.BlockStatement{
 		Token: p.curToken,
 	}
 
+	outerBlockDepth := p.blockDepth
+	p.blockDepth = p.braceDepth
+	defer func() { p.blockDepth = outerBlockDepth }()
+	// error before the block is handled by the statement, that contains it
+	outerPanicking := p.panicking
+	first := p.curIndex()
+
 	p.readToken() // consume token.LBRACE
 
 	for p.curToken.Type != token.RBRACE && p.curToken.Type != token.EOF {
 		stmt := p.parseStatement()
+		if p.panicking && !outerPanicking {
+			p.synchronize()
+			continue
+		}
 		if stmt != nil {
 			blockStmt.Statements = append(blockStmt.Statements, stmt)
 		}
 		p.readToken()
 	}
 
+	if p.curToken.Type == token.EOF {
+		p.report(diag.Diagnostic{
+			Severity:  diag.Error,
+			Code:      diag.UnclosedBlock,
+			Message:   "expected } at end of block",
+			Primary:   tokenSpan(p.curToken),
+			Secondary: []diag.Label{{Span: tokenSpan(blockStmt.Token), Message: "block starts here"}},
+		})
+	}
+
 	// p.readToken() // consume token.RBRACE
 
+	p.trackNode(&blockStmt, first)
+
 	return &blockStmt
 }
 
@@ -346,11 +805,26 @@ func (p *Parser) parseFnExpression() ast.Expression {
 		return nil
 	}
 
-	fnExpr.Body = p.parseBlockStatement()
+	fnExpr.Body, fnExpr.IsGenerator = p.parseFnBody()
 
 	return &fnExpr
 }
 
+// parseFnBody parses body of fn and reports whether it contains yield
+func (p *Parser) parseFnBody() (*ast.BlockStatement, bool) {
+	outerSawYield := p.sawYield
+	p.sawYield = false
+	p.fnDepth++
+
+	body := p.parseBlockStatement()
+
+	p.fnDepth--
+	isGenerator := p.sawYield
+	p.sawYield = outerSawYield
+
+	return body, isGenerator
+}
+
 func (p *Parser) parseFnParams() []*ast.Identifier {
 	if p.nextToken.Type == token.RPAREN { // empty param list
 		p.readToken()
@@ -427,8 +901,17 @@ func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 
 	p.readToken() // consume [
 
+	if p.curToken.Type == token.COLON { // slice without start
+		return p.parseSliceExpression(left, indexExp.Token, nil)
+	}
+
 	indexExp.Index = p.parseExpression(LOWEST)
 
+	if p.nextToken.Type == token.COLON {
+		p.readToken()
+		return p.parseSliceExpression(left, indexExp.Token, indexExp.Index)
+	}
+
 	if !p.isNextToken(token.RBRACKET) {
 		p.appendErrorf("expected ] after index")
 		return nil
@@ -437,6 +920,43 @@ func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 	return &indexExp
 }
 
+// parseSliceExpression parses end of the slice, current token is colon
+func (p *Parser) parseSliceExpression(left ast.Expression, tok token.Token, start ast.Expression) ast.Expression {
+	sliceExp := ast.SliceExpression{
+		Token: tok,
+		Left:  left,
+		Start: start,
+	}
+
+	if p.nextToken.Type != token.RBRACKET {
+		p.readToken() // consume :
+		sliceExp.End = p.parseExpression(LOWEST)
+	}
+
+	if !p.isNextToken(token.RBRACKET) {
+		p.appendErrorf("expected ] after slice")
+		return nil
+	}
+
+	return &sliceExp
+}
+
+func (p *Parser) parseMemberExpression(left ast.Expression) ast.Expression {
+	memberExp := ast.MemberExpression{
+		Token:  p.curToken,
+		Object: left,
+	}
+
+	if !p.isNextToken(token.IDENT) {
+		p.appendErrorf("expected member name after .")
+		return nil
+	}
+
+	memberExp.Member = p.parseIdentifier().(*ast.Identifier)
+
+	return &memberExp
+}
+
 func (p *Parser) parseHashExpression() ast.Expression {
 	hashExpr := ast.HashLiteral{
 		Token: p.curToken,
@@ -476,18 +996,55 @@ func (p *Parser) isNextToken(tt token.Type) bool {
 		p.readToken()
 		return true
 	}
-	p.appendErrorf("expect next token to be %q, got %q instead", tt, p.nextToken.Type)
+	if p.nextToken.Type == token.INVALID {
+		p.panicking = true // already reported
+		return false
+	}
+	if p.panicking {
+		return false
+	}
+	p.report(diag.Diagnostic{
+		Severity: diag.Error,
+		Code:     diag.UnexpectedToken,
+		Message:  fmt.Sprintf("expect next token to be %q, got %q instead", tt, p.nextToken.Type),
+		Primary:  tokenSpan(p.nextToken),
+	})
+	p.annotate = true
 	return false
 }
 
-func (p *Parser) skipUntilSemicolon() {
-	for p.curToken.Type != token.SEMICOLON && p.curToken.Type != token.EOF {
-		p.readToken()
+func (p *Parser) appendErrorf(text string, args ...interface{}) {
+	p.report(diag.Diagnostic{
+		Severity: diag.Error,
+		Code:     diag.InvalidSyntax,
+		Message:  fmt.Sprintf(text, args...),
+		Primary:  tokenSpan(p.curToken),
+	})
+}
+
+// report records the first error of the statement and starts panicking.
+// The only error reported after it is the explanation of unexpected token
+// by the caller of isNextToken, which becomes hint of the error.
+func (p *Parser) report(d diag.Diagnostic) {
+	if p.panicking {
+		if p.annotate {
+			last := &p.diagnostics[len(p.diagnostics)-1]
+			last.Hints = append(last.Hints, d.Message)
+			p.annotate = false
+		}
+		return
 	}
+	p.diagnostics = append(p.diagnostics, d)
+	p.panicking = true
 }
 
-func (p *Parser) appendErrorf(text string, args ...interface{}) {
-	p.errors = append(p.errors, fmt.Sprintf(text, args...))
+// tokenSpan returns span of the token in the source.
+func tokenSpan(tok token.Token) diag.Span {
+	text := tok.Literal
+	if tok.Type == token.STRING {
+		text = `"` + text + `"`
+	}
+	return diag.SpanOf(tok.Pos, text)
 }
 
 func (p *Parser) registerPrefix(tokenType token.Type, fn prefixParseFn) {
@@ -2,11 +2,14 @@ package parser
 
 import (
 	"fmt"
+	"reflect"
 	"strconv"
 	"testing"
 
 	"github.com/pechorka/plang/ast"
+	"github.com/pechorka/plang/diag"
 	"github.com/pechorka/plang/lexer"
+	"github.com/pechorka/plang/token"
 )
 
 func TestLetStatements(t *testing.T) {
@@ -134,6 +137,245 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestFloatLiteralExpression(t *testing.T) {
+	input := "3.25;"
+
+	stmt := getExpressionStmt(t, input)
+
+	literal, ok := stmt.Expression.(*ast.FloatLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.FloatLiteral. got=%T", stmt.Expression)
+	}
+	if literal.Value != 3.25 {
+		t.Errorf("literal.Value not %g. got=%g", 3.25, literal.Value)
+	}
+	if literal.TokenLiteral() != "3.25" {
+		t.Errorf("literal.TokenLiteral not %s. got=%s", "3.25",
+			literal.TokenLiteral())
+	}
+}
+
+func TestIntegerLiteralBases(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"1_000_000", 1000000},
+		{"0xff", 255},
+		{"0XFF_FF", 65535},
+		{"0o17", 15},
+		{"0b1010", 10},
+		{"0B1_0", 2},
+		{"012", 12},
+		{"0x7fffffffffffffff", 9223372036854775807},
+	}
+
+	for _, tt := range tests {
+		stmt := getExpressionStmt(t, tt.input)
+		literal, ok := stmt.Expression.(*ast.IntegerLiteral)
+		if !ok {
+			t.Fatalf("%s: exp not *ast.IntegerLiteral. got=%T", tt.input, stmt.Expression)
+		}
+		if literal.Value != tt.expected {
+			t.Errorf("%s: literal.Value not %d. got=%d", tt.input, tt.expected, literal.Value)
+		}
+		if literal.TokenLiteral() != tt.input {
+			t.Errorf("literal.TokenLiteral not %s. got=%s", tt.input, literal.TokenLiteral())
+		}
+	}
+
+	stmt := getExpressionStmt(t, "1_000.2_5")
+	literal, ok := stmt.Expression.(*ast.FloatLiteral)
+	if !ok || literal.Value != 1000.25 {
+		t.Errorf("1_000.2_5 not parsed as float 1000.25. got=%#v", stmt.Expression)
+	}
+}
+
+func TestMalformedNumberErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"0xZZ", `malformed hexadecimal literal "0xZZ": invalid digit 'Z'`},
+		{"let x = 12abc;", `malformed decimal literal "12abc": invalid digit 'a'`},
+		{"0x", `malformed hexadecimal literal "0x": no digits`},
+		{"0b102", `malformed binary literal "0b102": invalid digit '2'`},
+		{"0o8", `malformed octal literal "0o8": invalid digit '8'`},
+		{"1__0", `malformed decimal literal "1__0": '_' must separate successive digits`},
+		{"1_", `malformed decimal literal "1_": '_' must separate successive digits`},
+		{"1.5e3", `malformed decimal literal "1.5e3": invalid digit 'e'`},
+		{"0x1_0000_0000_0000_0000", `cant parse "0x1_0000_0000_0000_0000" as 64-bit integer`},
+	}
+
+	for i, tt := range tests {
+		p := New(lexer.NewFromString(tt.input))
+		p.Parse()
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Fatalf("test[%d]: expected error", i)
+		}
+		if errors[0] != tt.expectedError {
+			t.Errorf("test[%d]: wrong error. expected=%q, got=%q",
+				i, tt.expectedError, errors[0])
+		}
+	}
+}
+
+func TestLexerErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{`let s = "abc`, "unterminated string literal"},
+		{`fn(x) { x + "abc }`, "unterminated string literal"},
+		{"1 @ 2", "unexpected character '@'"},
+		{"let x = [1, \x00];", `unexpected character '\x00'`},
+		{"let x = \xff;", `invalid UTF-8 encoding "\xff"`},
+	}
+
+	for i, tt := range tests {
+		p := New(lexer.NewFromString(tt.input))
+		p.Parse()
+		errors := p.Errors()
+		if len(errors) != 1 {
+			t.Fatalf("test[%d]: expected 1 error, got %q", i, errors)
+		}
+		if errors[0] != tt.expectedError {
+			t.Errorf("test[%d]: wrong error. expected=%q, got=%q",
+				i, tt.expectedError, errors[0])
+		}
+	}
+}
+
+func TestErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedErrors []string
+		expectedProg   string
+	}{
+		{
+			"let = 1; let x = 2 +; x",
+			[]string{
+				`expect next token to be "IDENT", got "=" instead`,
+				`no prefix func for ";" token type`,
+			},
+			"x",
+		},
+		{
+			// errors in blocks are recovered at the closing brace, literals don't close blocks
+			"let f = fn() { let = 1; let h = {1 2}; return 5 }; let y = 2 * 3;",
+			[]string{
+				`expect next token to be "IDENT", got "=" instead`,
+				`expect next token to be ":", got "INT" instead`,
+				"expected colon after key",
+			},
+			"let f = fn (fn )return 5;;let y = (2 * 3);",
+		},
+		{
+			"}}; let x = ;\nlet y = 1",
+			[]string{
+				`no prefix func for "}" token type`,
+				`no prefix func for ";" token type`,
+			},
+			"let y = 1;",
+		},
+		{
+			// statement keyword starts a new statement, even without semicolon
+			"if (x { 1 } let y = )\nreturn 1",
+			[]string{
+				`expect next token to be ")", got "{" instead`,
+				`no prefix func for ")" token type`,
+			},
+			"return 1;",
+		},
+		{
+			// nothing is reported after the end of input
+			"let f = fn(x) { puts(x + \"a }; f(1);",
+			[]string{"unterminated string literal"},
+			"",
+		},
+		{
+			"fn() { 1",
+			[]string{"expected } at end of block"},
+			"",
+		},
+		{
+			"fn(x) { return x }",
+			nil,
+			"fn (xfn )return x;",
+		},
+	}
+
+	for i, tt := range tests {
+		p := New(lexer.NewFromString(tt.input))
+		prog := p.Parse()
+		errors := p.Errors()
+		if len(errors) != len(tt.expectedErrors) {
+			t.Errorf("test[%d]: wrong errors. expected=%q, got=%q", i, tt.expectedErrors, errors)
+			continue
+		}
+		for j, err := range errors {
+			if err != tt.expectedErrors[j] {
+				t.Errorf("test[%d]: wrong error %d. expected=%q, got=%q", i, j, tt.expectedErrors[j], err)
+			}
+		}
+		if prog.String() != tt.expectedProg {
+			t.Errorf("test[%d]: wrong program. expected=%q, got=%q", i, tt.expectedProg, prog.String())
+		}
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	input := `let = 1;
+try { x } catch { y }
+let s = "abc
+fn() {`
+	p := New(lexer.NewFromString(input))
+	p.Parse()
+
+	pos := func(line, column int) token.Position {
+		return token.Position{Line: line, Column: column}
+	}
+	expected := []diag.Diagnostic{
+		{
+			Severity: diag.Error,
+			Code:     diag.UnexpectedToken,
+			Message:  `expect next token to be "IDENT", got "=" instead`,
+			Primary:  diag.Span{Start: pos(1, 5), End: pos(1, 6)},
+		},
+		{
+			Severity: diag.Error,
+			Code:     diag.UnexpectedToken,
+			Message:  `expect next token to be "(", got "{" instead`,
+			Primary:  diag.Span{Start: pos(2, 17), End: pos(2, 18)},
+			Hints:    []string{"invalid try expression: no ( after catch"},
+		},
+		{
+			Severity: diag.Error,
+			Code:     diag.UnterminatedString,
+			Message:  "unterminated string literal",
+			Primary:  diag.Span{Start: pos(3, 9), End: pos(4, 7)},
+		},
+	}
+	diags := p.Diagnostics()
+	if !reflect.DeepEqual(diags, expected) {
+		t.Fatalf("wrong diagnostics.\nexpected=%+v\ngot=%+v", expected, diags)
+	}
+
+	p = New(lexer.NewFromString("fn() {\n  1"))
+	p.Parse()
+	expected = []diag.Diagnostic{{
+		Severity:  diag.Error,
+		Code:      diag.UnclosedBlock,
+		Message:   "expected } at end of block",
+		Primary:   diag.Span{Start: pos(2, 4), End: pos(2, 4)},
+		Secondary: []diag.Label{{Span: diag.Span{Start: pos(1, 6), End: pos(1, 7)}, Message: "block starts here"}},
+	}}
+	if diags := p.Diagnostics(); !reflect.DeepEqual(diags, expected) {
+		t.Fatalf("wrong diagnostics.\nexpected=%+v\ngot=%+v", expected, diags)
+	}
+}
+
 func TestStringLiteralExpression(t *testing.T) {
 	input := `"foobar";`
 
@@ -349,6 +591,26 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"add(a * b[2], b[1], 2 * [1, 2][1])",
 			"add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))",
 		},
+		{
+			"a[1:2] + b[:c][d:]",
+			"((a[1:2]) + ((b[:c])[d:]))",
+		},
+		{
+			"a[:]",
+			"(a[:])",
+		},
+		{
+			"-p.x * p.y",
+			"((-(p.x)) * (p.y))",
+		},
+		{
+			"a.b.c[0]",
+			"(((a.b).c)[0])",
+		},
+		{
+			"p.sum(1) + 2",
+			"((p.sum)(1) + 2)",
+		},
 	}
 
 	for i, tt := range tests {
@@ -649,6 +911,24 @@ func TestParsingIndexExpressions(t *testing.T) {
 	}
 }
 
+func TestParsingSliceExpressions(t *testing.T) {
+	input := "myArray[1:2 + 3]"
+	stmt := getExpressionStmt(t, input)
+	sliceExp, ok := stmt.Expression.(*ast.SliceExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.SliceExpression. got=%T", stmt.Expression)
+	}
+	if !testIdentifier(t, sliceExp.Left, "myArray") {
+		return
+	}
+	if !testIntegerLiteral(t, sliceExp.Start, 1) {
+		return
+	}
+	if !testInfixExpression(t, sliceExp.End, 2, "+", 3) {
+		return
+	}
+}
+
 func TestParsingHashLiteralsStringKeys(t *testing.T) {
 	input := `{"one": 1, "two": 2, "three": 3}`
 	stmt := getExpressionStmt(t, input)
@@ -771,6 +1051,260 @@ func TestParsingHashLiteralsWithExpressions(t *testing.T) {
 	}
 }
 
+func TestStructStatement(t *testing.T) {
+	input := `struct Point {
+		x, y
+		fn add(other) { Point(self.x + other.x, self.y + other.y) }
+		fn zero() { Point(0, 0) }
+	}`
+	l := lexer.NewFromString(input)
+	p := New(l)
+	program := p.Parse()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program has not enough statements. got=%d",
+			len(program.Statements))
+	}
+	stmt, ok := program.Statements[0].(*ast.StructStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.StructStatement. got=%T",
+			program.Statements[0])
+	}
+	if !testIdentifier(t, stmt.Name, "Point") {
+		return
+	}
+	if len(stmt.Fields) != 2 {
+		t.Fatalf("stmt.Fields has not 2 fields. got=%d", len(stmt.Fields))
+	}
+	testIdentifier(t, stmt.Fields[0], "x")
+	testIdentifier(t, stmt.Fields[1], "y")
+
+	if len(stmt.Methods) != 2 {
+		t.Fatalf("stmt.Methods has not 2 methods. got=%d", len(stmt.Methods))
+	}
+	add := stmt.Methods[0]
+	testIdentifier(t, add.Name, "add")
+	if len(add.Fn.Params) != 1 {
+		t.Fatalf("add has wrong params. got=%d", len(add.Fn.Params))
+	}
+	testIdentifier(t, add.Fn.Params[0], "other")
+	if len(add.Fn.Body.Statements) != 1 {
+		t.Fatalf("add body has wrong number of statements. got=%d",
+			len(add.Fn.Body.Statements))
+	}
+	testIdentifier(t, stmt.Methods[1].Name, "zero")
+}
+
+func TestStructStatementErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"struct { x }", `expect next token to be "IDENT", got "{" instead`},
+		{"struct P { x, x }", `duplicate member "x" in struct P`},
+		{"struct P { x; fn x() {} }", `duplicate member "x" in struct P`},
+		{"struct P { 1 }", `unexpected "INT" in struct P`},
+		{"struct P { fn () {} }", `expect next token to be "IDENT", got "(" instead`},
+	}
+
+	for i, tt := range tests {
+		l := lexer.NewFromString(tt.input)
+		p := New(l)
+		p.Parse()
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Fatalf("test[%d]: expected error", i)
+		}
+		if errors[0] != tt.expectedError {
+			t.Errorf("test[%d]: wrong error. expected=%q, got=%q",
+				i, tt.expectedError, errors[0])
+		}
+	}
+}
+
+func TestThrowStatement(t *testing.T) {
+	l := lexer.NewFromString(`throw "oops";`)
+	p := New(l)
+	program := p.Parse()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program has not enough statements. got=%d",
+			len(program.Statements))
+	}
+	stmt, ok := program.Statements[0].(*ast.ThrowStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ThrowStatement. got=%T",
+			program.Statements[0])
+	}
+	literal, ok := stmt.Value.(*ast.StringLiteral)
+	if !ok {
+		t.Fatalf("stmt.Value is not ast.StringLiteral. got=%T", stmt.Value)
+	}
+	if literal.Value != "oops" {
+		t.Errorf("literal.Value not %q. got=%q", "oops", literal.Value)
+	}
+}
+
+func TestTryExpression(t *testing.T) {
+	tests := []struct {
+		input      string
+		catchParam string
+		hasFinally bool
+	}{
+		{"try { x } catch (e) { y }", "e", false},
+		{"try { x } finally { z }", "", true},
+		{"try { x } catch (err) { y } finally { z }", "err", true},
+	}
+
+	for _, tt := range tests {
+		stmt := getExpressionStmt(t, tt.input)
+		exp, ok := stmt.Expression.(*ast.TryExpression)
+		if !ok {
+			t.Fatalf("exp not *ast.TryExpression. got=%T", stmt.Expression)
+		}
+		if len(exp.Block.Statements) != 1 {
+			t.Fatalf("exp.Block has wrong number of statements. got=%d",
+				len(exp.Block.Statements))
+		}
+		if tt.catchParam == "" {
+			if exp.Catch != nil {
+				t.Errorf("exp.Catch was not nil")
+			}
+		} else {
+			if exp.Catch == nil {
+				t.Fatalf("exp.Catch was nil")
+			}
+			testIdentifier(t, exp.CatchParam, tt.catchParam)
+		}
+		if (exp.Finally != nil) != tt.hasFinally {
+			t.Errorf("exp.Finally presence wrong. expected=%t", tt.hasFinally)
+		}
+	}
+}
+
+func TestTryExpressionErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"try { x }", "invalid try expression: expected catch or finally"},
+		{"try { x } catch { y }", "invalid try expression: no ( after catch"},
+		{"try { x } catch (1) { y }", "invalid try expression: catch expects a variable name"},
+	}
+
+	for i, tt := range tests {
+		l := lexer.NewFromString(tt.input)
+		p := New(l)
+		p.Parse()
+		found := false
+		for _, err := range p.Errors() {
+			if err == tt.expectedError {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("test[%d]: expected error %q, got=%q", i, tt.expectedError, p.Errors())
+		}
+	}
+}
+
+func TestForExpression(t *testing.T) {
+	input := "for (x in xs) { puts(x) }"
+
+	stmt := getExpressionStmt(t, input)
+
+	exp, ok := stmt.Expression.(*ast.ForExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.ForExpression. got=%T", stmt.Expression)
+	}
+	if !testIdentifier(t, exp.Variable, "x") {
+		return
+	}
+	if !testIdentifier(t, exp.Iterable, "xs") {
+		return
+	}
+	if len(exp.Body.Statements) != 1 {
+		t.Fatalf("exp.Body has wrong number of statements. got=%d",
+			len(exp.Body.Statements))
+	}
+}
+
+func TestSpawnExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"spawn work(1, 2)", "spawn work(1, 2)"},
+		{"spawn fn() { x }", "spawn fn (fn )x"},
+		{"wait(spawn f())", "wait(spawn f())"},
+	}
+
+	for _, tt := range tests {
+		stmt := getExpressionStmt(t, tt.input)
+		if stmt.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, stmt.String())
+		}
+	}
+
+	stmt := getExpressionStmt(t, "spawn work(1)")
+	exp, ok := stmt.Expression.(*ast.SpawnExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.SpawnExpression. got=%T", stmt.Expression)
+	}
+	if _, ok := exp.Call.(*ast.CallExpression); !ok {
+		t.Fatalf("exp.Call not *ast.CallExpression. got=%T", exp.Call)
+	}
+}
+
+func TestGeneratorDetection(t *testing.T) {
+	tests := []struct {
+		input       string
+		isGenerator bool
+	}{
+		{"fn() { 1 }", false},
+		{"fn() { yield 1 }", true},
+		{"fn() { if (true) { yield 1; } }", true},
+		{"fn() { fn() { yield 1 } }", false},
+	}
+
+	for _, tt := range tests {
+		stmt := getExpressionStmt(t, tt.input)
+		exp, ok := stmt.Expression.(*ast.FnExpression)
+		if !ok {
+			t.Fatalf("exp not *ast.FnExpression. got=%T", stmt.Expression)
+		}
+		if exp.IsGenerator != tt.isGenerator {
+			t.Errorf("%q: IsGenerator wrong. expected=%t, got=%t",
+				tt.input, tt.isGenerator, exp.IsGenerator)
+		}
+	}
+}
+
+func TestYieldOutsideFn(t *testing.T) {
+	l := lexer.NewFromString("yield 1")
+	p := New(l)
+	p.Parse()
+	errors := p.Errors()
+	if len(errors) == 0 || errors[0] != "yield outside of fn" {
+		t.Errorf("expected yield outside of fn error, got=%q", errors)
+	}
+}
+
+func TestParsingMemberExpressions(t *testing.T) {
+	input := "point.x"
+	stmt := getExpressionStmt(t, input)
+	memberExp, ok := stmt.Expression.(*ast.MemberExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.MemberExpression. got=%T", stmt.Expression)
+	}
+	if !testIdentifier(t, memberExp.Object, "point") {
+		return
+	}
+	testIdentifier(t, memberExp.Member, "x")
+}
+
 func testLetStatement(t *testing.T, s ast.Statement, name string) bool {
 	if s.TokenLiteral() != "let" {
 		t.Errorf("s.TokenLiteral not 'let'. got=%q", s.TokenLiteral())
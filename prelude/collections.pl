@@ -0,0 +1,36 @@
+// keys returns keys of the hash in sorted order.
+let keys = fn(hash) { map(hash, fn(kv) { kv[0] }) };
+
+// values returns values of the hash in the order of their keys.
+let values = fn(hash) { map(hash, fn(kv) { kv[1] }) };
+
+// sum adds numbers of the iterable, sum of no numbers is 0.
+let sum = fn(xs) { reduce(xs, fn(acc, x) { acc + x }, 0) };
+
+// product multiplies numbers of the iterable, product of no numbers is 1.
+let product = fn(xs) { reduce(xs, fn(acc, x) { acc * x }, 1) };
+
+// count returns number of elements, that satisfy the predicate.
+let count = fn(xs, pred) { len(filter(xs, pred)) };
+
+// flatten concatenates arrays of the iterable into one array.
+let flatten = fn(xss) {
+	reduce(xss, fn(acc, xs) { reduce(xs, push, acc) }, [])
+};
+
+// flat_map maps elements to arrays and concatenates them.
+let flat_map = fn(xs, f) { flatten(map(xs, f)) };
+
+// partition splits elements into [satisfying, not satisfying] the predicate.
+let partition = fn(xs, pred) {
+	reduce(xs, fn(acc, x) {
+		if (pred(x)) { [push(acc[0], x), acc[1]] } else { [acc[0], push(acc[1], x)] }
+	}, [[], []])
+};
+
+// chunk splits elements into arrays of the given size, the last one may be shorter.
+let chunk = fn(xs, size) {
+	if (size < 1) { throw sprintf("chunk: size must be positive, got %d", size) };
+	let groups = group_by(enumerate(xs), fn(p) { p[0] / size });
+	map(values(groups), fn(group) { map(group, fn(p) { p[1] }) })
+};
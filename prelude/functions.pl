@@ -0,0 +1,5 @@
+// identity returns its argument, e.g. as a default key function.
+let identity = fn(x) { x };
+
+// compose returns function, that applies g and then f: compose(f, g)(x) == f(g(x)).
+let compose = fn(f, g) { fn(x) { f(g(x)) } };
@@ -0,0 +1,43 @@
+// Package prelude provides standard functions, that are written in plang itself.
+package prelude
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+
+	"github.com/pechorka/plang/evaluator"
+	"github.com/pechorka/plang/lexer"
+	"github.com/pechorka/plang/object"
+	"github.com/pechorka/plang/parser"
+)
+
+//go:embed *.pl
+var sources embed.FS
+
+// Load evaluates the prelude with the evaluator into a new environment.
+// Code should run in an environment enclosed by it,
+// so definitions of the code shadow the prelude and don't change it.
+func Load(e *evaluator.Evaluator) (*object.Environment, error) {
+	env := object.NewEnvironment()
+	names, err := fs.Glob(sources, "*.pl")
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		f, err := sources.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		p := parser.New(lexer.NewNamed("prelude/"+name, f))
+		program := p.Parse()
+		f.Close()
+		if len(p.Errors()) != 0 {
+			return nil, fmt.Errorf("prelude/%s: %s", name, p.Diagnostics()[0])
+		}
+		if errObj, ok := e.Eval(program, env).(*object.Error); ok {
+			return nil, fmt.Errorf("prelude/%s:%s: %s: %s", name, errObj.Pos, errObj.Kind, errObj.Message)
+		}
+	}
+	return env, nil
+}
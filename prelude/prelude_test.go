@@ -0,0 +1,117 @@
+package prelude
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/pechorka/plang/evaluator"
+	"github.com/pechorka/plang/lexer"
+	"github.com/pechorka/plang/object"
+	"github.com/pechorka/plang/parser"
+)
+
+func TestPrelude(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`identity(5)`, `5`},
+		{`compose(fn(x) { x + 1 }, fn(x) { x * 2 })(5)`, `11`},
+		{`compose(len, identity)("abc")`, `3`},
+		{`keys({"b": 1, "a": 2})`, `[a, b]`},
+		{`keys({})`, `[]`},
+		{`values({"b": 1, "a": 2})`, `[2, 1]`},
+		{`sum([1, 2, 3])`, `6`},
+		{`sum([1, 2.5])`, `3.5`},
+		{`sum([])`, `0`},
+		{`product([2, 3, 4])`, `24`},
+		{`product([])`, `1`},
+		{`count([1, 2, 3, 4], fn(x) { x > 2 })`, `2`},
+		{`count([], fn(x) { true })`, `0`},
+		{`flatten([[1, 2], [], [3]])`, `[1, 2, 3]`},
+		{`flatten([])`, `[]`},
+		{`flat_map([1, 2], fn(x) { [x, x * 10] })`, `[1, 10, 2, 20]`},
+		{`partition([1, 2, 3, 4], fn(x) { x > 2 })`, `[[3, 4], [1, 2]]`},
+		{`partition([], fn(x) { true })`, `[[], []]`},
+		{`chunk([1, 2, 3, 4, 5], 2)`, `[[1, 2], [3, 4], [5]]`},
+		{`chunk([], 2)`, `[]`},
+		{`chunk([1], 0)`, `ERROR: chunk: size must be positive, got 0`},
+	}
+
+	for _, tt := range tests {
+		got := testEval(t, tt.input).Inspect()
+		if got != tt.expected {
+			t.Errorf("%s: expected=%s, got=%s", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestPreludeCanBeShadowed(t *testing.T) {
+	e := evaluator.New()
+	base, err := Load(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := eval(t, e, object.NewEnclosedEnvironment(base), `let sum = fn(xs) { 42 }; sum([1])`)
+	if got.Inspect() != "42" {
+		t.Errorf("shadowed sum returned %s", got.Inspect())
+	}
+	got = eval(t, e, object.NewEnclosedEnvironment(base), `sum([1])`)
+	if got.Inspect() != "1" {
+		t.Errorf("prelude sum was changed by other code, returned %s", got.Inspect())
+	}
+}
+
+func TestPreludeUsesEvaluator(t *testing.T) {
+	var out bytes.Buffer
+	e := evaluator.New(evaluator.WithOutput(&out))
+	base, err := Load(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	eval(t, e, object.NewEnclosedEnvironment(base), `compose(print, sum)([1, 2])`)
+	if out.String() != "3" {
+		t.Errorf("wrong output. expected=%q, got=%q", "3", out.String())
+	}
+}
+
+func TestPreludeErrorsAtCall(t *testing.T) {
+	e := evaluator.New()
+	base, err := Load(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := parser.New(lexer.NewNamed("script.pl", strings.NewReader("let x = 1;\nsum([\"a\", 1])")))
+	obj := e.Eval(p.Parse(), object.NewEnclosedEnvironment(base))
+	errObj, ok := obj.(*object.Error)
+	if !ok {
+		t.Fatalf("expected error, got %T (%+v)", obj, obj)
+	}
+	if errObj.Pos.Source != "script.pl" || errObj.Pos.Line != 2 {
+		t.Errorf("error is not at the call. got=%+v", errObj.Pos)
+	}
+	if errObj.Origin.Source != "prelude/collections.pl" {
+		t.Errorf("wrong origin of the error. got=%+v", errObj.Origin)
+	}
+}
+
+func testEval(t *testing.T, input string) object.Object {
+	t.Helper()
+	e := evaluator.New()
+	base, err := Load(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return eval(t, e, object.NewEnclosedEnvironment(base), input)
+}
+
+func eval(t *testing.T, e *evaluator.Evaluator, env *object.Environment, input string) object.Object {
+	t.Helper()
+	p := parser.New(lexer.NewFromString(input))
+	program := p.Parse()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("%s: parser errors: %v", input, p.Errors())
+	}
+	return e.Eval(program, env)
+}
@@ -3,36 +3,60 @@ package repl
 import (
 	"bufio"
 	"fmt"
-
 	"io"
+	"strings"
 
+	"github.com/pechorka/plang/diag"
 	"github.com/pechorka/plang/evaluator"
 	"github.com/pechorka/plang/lexer"
 	"github.com/pechorka/plang/object"
 	"github.com/pechorka/plang/parser"
+	"github.com/pechorka/plang/prelude"
 )
 
 const PROMPT = ">> "
 
-func Start(r io.Reader, w io.Writer) {
+// Start runs read-eval-print loop. The entered code sees the prelude, if withPrelude is set.
+// Options configure the evaluator, e.g. grant capabilities to the entered code.
+func Start(r io.Reader, w io.Writer, withPrelude bool, opts ...evaluator.Option) {
 	scanner := bufio.NewScanner(r)
+	// output of the entered code goes to the same writer, as results
+	eval := evaluator.New(append([]evaluator.Option{evaluator.WithOutput(w)}, opts...)...)
 	env := object.NewEnvironment()
-	for {
+	if withPrelude {
+		base, err := prelude.Load(eval)
+		if err != nil {
+			fmt.Fprintln(w, err)
+			return
+		}
+		env = object.NewEnclosedEnvironment(base)
+	}
+	for n := 1; ; n++ {
 		fmt.Fprint(w, PROMPT)
 		scanned := scanner.Scan()
 		if !scanned {
 			return
 		}
 		line := scanner.Text()
-		l := lexer.NewFromString(line)
+		// every line is a source of its own, so errors in functions
+		// from previous lines are shown at their calls in this line
+		name := fmt.Sprintf("<input %d>", n)
+		l := lexer.NewNamed(name, strings.NewReader(line))
 		p := parser.New(l)
 
 		program := p.Parse()
-		if len(p.Errors()) != 0 {
-			printParserErrors(w, p.Errors())
+		if len(p.Diagnostics()) != 0 {
+			printDiagnostics(w, name, line, p.Diagnostics())
+			continue
+		}
+		evaluated := eval.Eval(program, env)
+		if errObj, ok := evaluated.(*object.Error); ok {
+			if _, ok := errObj.ExitCode(); ok {
+				return
+			}
+			printDiagnostics(w, name, line, []diag.Diagnostic{errObj.Diagnostic()})
 			continue
 		}
-		evaluated := evaluator.Eval(program, env)
 		if evaluated != nil {
 			// don't print functions
 			if _, ok := evaluated.(*object.Function); ok {
@@ -44,8 +68,10 @@ func Start(r io.Reader, w io.Writer) {
 	}
 }
 
-func printParserErrors(w io.Writer, errors []string) {
-	for _, msg := range errors {
-		io.WriteString(w, "\t"+msg+"\n")
-	}
+// printDiagnostics prints errors of the entered line under it.
+// They are coloured, if w is a terminal.
+func printDiagnostics(w io.Writer, name, line string, diags []diag.Diagnostic) {
+	r := diag.NewRenderer(name, line)
+	r.Color = diag.Colorable(w)
+	r.Render(w, diags...)
 }
@@ -1,5 +1,7 @@
 package token
 
+import "strconv"
+
 type Type string
 
 const (
@@ -8,6 +10,7 @@ const (
 	// Identifiers + literals
 	IDENT  Type = "IDENT" // add, foobar, x, y, ...
 	INT    Type = "INT"   // 1343456
+	FLOAT  Type = "FLOAT" // 3.14
 	STRING Type = "STRING"
 	// Operators
 	ASSIGN   Type = "="
@@ -24,6 +27,7 @@ const (
 	COMMA     Type = ","
 	SEMICOLON Type = ";"
 	COLON     Type = ":"
+	DOT       Type = "."
 	LPAREN    Type = "("
 	RPAREN    Type = ")"
 	LBRACE    Type = "{"
@@ -39,9 +43,36 @@ const (
 	IF       Type = "IF"
 	ELSE     Type = "ELSE"
 	RETURN   Type = "RETURN"
+	STRUCT   Type = "STRUCT"
+	THROW    Type = "THROW"
+	TRY      Type = "TRY"
+	CATCH    Type = "CATCH"
+	FINALLY  Type = "FINALLY"
+	YIELD    Type = "YIELD"
+	FOR      Type = "FOR"
+	IN       Type = "IN"
+	SPAWN    Type = "SPAWN"
 )
 
 type Token struct {
 	Type    Type
 	Literal string
+	Pos     Position // position of the first rune of the token
+}
+
+// Position is a location in the source. Line and Column start from 1,
+// Column is counted in runes.
+type Position struct {
+	Line   int
+	Column int
+	Source string // name of the source, e.g. path of the script, empty if unnamed
+}
+
+// IsValid reports whether the position was set.
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+func (p Position) String() string {
+	return strconv.Itoa(p.Line) + ":" + strconv.Itoa(p.Column)
 }